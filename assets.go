@@ -0,0 +1,160 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Asset fingerprinting
+// For more information, see: https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity
+
+// Number of hex characters from the content hash, used in the fingerprinted file names
+const assetHashLen = 10
+
+// Cache-Control header for fingerprinted assets, which will never change
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+type assetEntry struct {
+	hashed    string // Fingerprinted path, like "css/app.3f2a9c1b4e.css"
+	integrity string // Subresource Integrity hash, like "sha384-..."
+}
+
+// AssetManifest maps static asset files to fingerprinted paths, which contains a hash of the file's content (like
+// "css/app.css" to "css/app.3f2a9c1b4e.css"). As the fingerprinted paths changes whenever a file is changed, they can
+// safely be cached forever by the clients.
+//
+// Use it in StaticOptions to serve the fingerprinted paths, and in MuxOptions to enable the "asset", "assetSRI" and
+// "assetTag" template funcs.
+type AssetManifest struct {
+	prefix string
+	assets map[string]assetEntry // Keyed by the original path
+	hashed map[string]string     // Maps fingerprinted paths to original paths
+}
+
+// NewAssetManifest hashes all files in fsys (use os.DirFS() for a directory on disk), ignoring any dotfiles.
+// The prefix is the URL path where the files are served, like "/static".
+//
+// NOTE: it will cause a panic on any errors.
+func NewAssetManifest(fsys fs.FS, prefix string) *AssetManifest {
+	am := &AssetManifest{
+		prefix: cleanPath(prefix),
+		assets: make(map[string]assetEntry),
+		hashed: make(map[string]string),
+	}
+	err := fs.WalkDir(fsys, ".", func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if fp != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return am.add(fsys, fp)
+	})
+	if err != nil {
+		panic(err)
+	}
+	return am
+}
+
+func (am *AssetManifest) add(fsys fs.FS, fp string) error {
+	f, err := fsys.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h256, h384 := sha256.New(), sha512.New384()
+	if _, err := io.Copy(io.MultiWriter(h256, h384), f); err != nil {
+		return errors.Wrap(err, "hashing asset "+fp)
+	}
+
+	ext := path.Ext(fp)
+	hash := hex.EncodeToString(h256.Sum(nil))[:assetHashLen]
+	hashed := strings.TrimSuffix(fp, ext) + "." + hash + ext
+	am.assets[fp] = assetEntry{
+		hashed:    hashed,
+		integrity: "sha384-" + base64.StdEncoding.EncodeToString(h384.Sum(nil)),
+	}
+	am.hashed[hashed] = fp
+	return nil
+}
+
+func (am *AssetManifest) lookup(name string) (assetEntry, error) {
+	a, found := am.assets[strings.TrimPrefix(path.Clean("/"+name), "/")]
+	if !found {
+		return a, errors.Errorf("unknown asset: %s", name)
+	}
+	return a, nil
+}
+
+// URL returns the fingerprinted URL for an asset, like "/static/css/app.3f2a9c1b4e.css" for "css/app.css".
+func (am *AssetManifest) URL(name string) (string, error) {
+	a, err := am.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(am.prefix, a.hashed), nil
+}
+
+// Integrity returns the Subresource Integrity hash for an asset, to be used in the "integrity" attribute of script
+// and link tags.
+func (am *AssetManifest) Integrity(name string) (string, error) {
+	a, err := am.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return a.integrity, nil
+}
+
+// Tag returns a script tag for ".js" assets or a stylesheet link tag for ".css" assets, with the fingerprinted URL
+// and the Subresource Integrity hash.
+func (am *AssetManifest) Tag(name string) (template.HTML, error) {
+	u, err := am.URL(name)
+	if err != nil {
+		return "", err
+	}
+	a, _ := am.lookup(name)
+	attrs := `="` + template.HTMLEscapeString(u) + `" integrity="` + a.integrity + `" crossorigin="anonymous"`
+	switch strings.ToLower(path.Ext(name)) {
+	case ".js":
+		return template.HTML(`<script src` + attrs + `></script>`), nil
+	case ".css":
+		return template.HTML(`<link rel="stylesheet" href` + attrs + `>`), nil
+	default:
+		return "", errors.Errorf("unsupported asset type for tag: %s", name)
+	}
+}
+
+// Resolve returns the original path for a fingerprinted path, or false if it's not a fingerprinted path.
+func (am *AssetManifest) Resolve(fp string) (string, bool) {
+	orig, found := am.hashed[strings.TrimPrefix(fp, "/")]
+	return orig, found
+}
+
+// served returns true if the URL path is under the manifest's prefix.
+func (am *AssetManifest) served(urlPath string) bool {
+	return strings.HasPrefix(urlPath, strings.TrimSuffix(am.prefix, "/")+"/")
+}
+
+// templateFuncs returns the template funcs using the manifest.
+func (am *AssetManifest) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"asset":    am.URL,
+		"assetSRI": am.Integrity,
+		"assetTag": am.Tag,
+	}
+}
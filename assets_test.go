@@ -0,0 +1,127 @@
+package web
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func newTestAssets() *AssetManifest {
+	return NewAssetManifest(os.DirFS("testdata/static"), "/static/")
+}
+
+func TestAssetManifest(t *testing.T) {
+	am := newTestAssets()
+	for _, name := range []string{".env", ".hidden/config", "files/.secret", ".well-known/security.txt"} {
+		if _, err := am.URL(name); err == nil {
+			t.Errorf("expected error for dotfile %q", name)
+		}
+	}
+
+	tests := map[string]string{
+		"css/app.css": `^/static/css/app\.[0-9a-f]{10}\.css$`,
+		"/js/app.js":  `^/static/js/app\.[0-9a-f]{10}\.js$`,
+		"data":        `^/static/data\.[0-9a-f]{10}$`,
+	}
+	for name, pattern := range tests {
+		u, err := am.URL(name)
+		if err != nil || !regexp.MustCompile(pattern).MatchString(u) {
+			t.Errorf("got URL %q and error %v for %q, wanted match for %q", u, err, name, pattern)
+			continue
+		}
+		orig, ok := am.Resolve(u[len("/static"):])
+		if !ok || orig != strings.TrimPrefix(name, "/") {
+			t.Errorf("got resolved path %q for %q, wanted %q", orig, u, name)
+		}
+	}
+	if _, ok := am.Resolve("/css/app.css"); ok {
+		t.Errorf("expected original path to not resolve")
+	}
+	if _, err := am.URL("missing.css"); err == nil {
+		t.Errorf("expected error for missing asset")
+	}
+
+	sum := sha512.Sum384([]byte("plain js"))
+	want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	if got, _ := am.Integrity("js/app.js"); got != want {
+		t.Errorf("got integrity %q, wanted %q", got, want)
+	}
+	if _, err := am.Tag("data"); err == nil {
+		t.Errorf("expected error for tag with unsupported asset type")
+	}
+}
+
+func TestAssetTemplates(t *testing.T) {
+	am := newTestAssets()
+	tmpl := template.Must(template.New("page.html").Funcs(templateFuncs(nil)).Parse(
+		`{{assetTag "css/app.css"}}{{assetTag "js/app.js"}}<img src="{{asset "data"}}">`))
+	m := NewMux(&MuxOptions{
+		Templates: map[string]*template.Template{"page.html": tmpl},
+		Assets:    am,
+	})
+	m.Register("GET", "/", func(c *Context) error {
+		return c.Render(200, "page.html", nil)
+	})
+
+	css, _ := am.URL("css/app.css")
+	cssSRI, _ := am.Integrity("css/app.css")
+	js, _ := am.URL("js/app.js")
+	jsSRI, _ := am.Integrity("js/app.js")
+	data, _ := am.URL("data")
+	resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Body(t, resp, `<link rel="stylesheet" href="`+css+`" integrity="`+cssSRI+`" crossorigin="anonymous">`+
+		`<script src="`+js+`" integrity="`+jsSRI+`" crossorigin="anonymous"></script>`+
+		`<img src="`+data+`">`)
+
+	t.Run("without assets", func(t *testing.T) {
+		tmpl := LoadTemplatesFS(fstest.MapFS{"a.html": {Data: []byte(`{{asset "css/app.css"}}`)}}, "*.html", nil)
+		m := NewMux(&MuxOptions{Templates: tmpl})
+		m.Register("GET", "/", func(c *Context) error {
+			return c.Render(200, "a.html", nil)
+		})
+		resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+		assert.StatusCode(t, resp, http.StatusInternalServerError)
+	})
+}
+
+func TestAssetStatic(t *testing.T) {
+	am := newTestAssets()
+	m := NewMux(nil)
+	m.StaticWithOptions("/static", staticFixture(t), &StaticOptions{
+		Assets:       am,
+		CacheControl: map[string]string{"*": "no-cache"},
+	})
+
+	u, _ := am.URL("css/app.css")
+	resp := assert.DoRequest(t, m, "GET", u, nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Body(t, resp, "body{color:red}\n")
+	assert.Header(t, resp, "Cache-Control", "public, max-age=31536000, immutable")
+	assert.Header(t, resp, "Content-Type", "text/css; charset=utf-8")
+
+	resp = assert.DoRequest(t, m, "GET", "/static/css/app.css", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Header(t, resp, "Cache-Control", "no-cache")
+
+	resp = assert.DoRequest(t, m, "GET", "/static/css/app.0000000000.css", nil, nil)
+	assert.StatusCode(t, resp, http.StatusNotFound)
+
+	t.Run("other mounts", func(t *testing.T) {
+		m := NewMux(&MuxOptions{Assets: am})
+		m.StaticFS("/static", os.DirFS("testdata/static"))
+		m.StaticWithOptions("/other", staticFixture(t), &StaticOptions{Assets: am})
+		for _, p := range []string{u, "/other" + strings.TrimPrefix(u, "/static")} {
+			resp := assert.DoRequest(t, m, "GET", p, nil, nil)
+			assert.StatusCode(t, resp, http.StatusNotFound)
+		}
+	})
+}
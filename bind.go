@@ -0,0 +1,262 @@
+package web
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Max memory used for multipart forms when binding, the rest of the form will be stored in temporary files on disk.
+const bindMaxMemory = 32 << 20
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// bindSource is a source of values for binding, which are looked up using the struct tag for the source.
+type bindSource struct {
+	tag    string
+	lookup func(key string) ([]string, bool)
+	// prefixed returns true if the source has any keys starting with prefix
+	prefixed func(prefix string) bool
+}
+
+func valuesSource(tag string, values map[string][]string) bindSource {
+	return bindSource{tag, func(key string) ([]string, bool) {
+		v, found := values[key]
+		return v, found && len(v) > 0
+	}, func(prefix string) bool {
+		for k := range values {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// Bind fills a struct with values from a request, using the struct tags for each field to look up the values from
+// the different sources. The sources are, in the order they are used:
+//
+//   - Request body, depending on the Content-Type: JSON ("application/json" using the "json" tags) or forms
+//     ("application/x-www-form-urlencoded" or "multipart/form-data" using the "form" tags)
+//   - URL query (using the "query" tags)
+//   - URL path params (using the "path" tags)
+//
+// Values are converted to the field's type and supported types are strings, bools, ints, uints, floats, time.Time
+// (using the optional "layout" tag, defaults to time.RFC3339), any type implementing encoding.TextUnmarshaler,
+// pointers and slices of the other types. Nested structs (and pointers to structs, which are allocated when any of
+// their values are found) will use their parent's tag as a prefix for their own tags, like "address.city" (or no
+// prefix if the parent has no tag).
+//
+// Fields without tags are ignored. All field errors are returned together as a '400 bad request' *Error, and
+// request bodies with other Content-Types will return a '415 unsupported media type' *Error.
+func (c *Context) Bind(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("bind: expected a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+
+	var errs []string
+	var sources []bindSource
+	if c.R.Body != nil && c.R.Body != http.NoBody && c.R.ContentLength != 0 {
+		mt, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		switch mt {
+		case "application/json":
+			defer c.R.Body.Close()
+			if err := json.NewDecoder(c.R.Body).Decode(dst); err != nil {
+				errs = append(errs, "invalid json body: "+err.Error())
+			}
+		case "application/x-www-form-urlencoded":
+			if err := c.R.ParseForm(); err != nil {
+				return c.Error(http.StatusBadRequest, "invalid form body")
+			}
+			sources = append(sources, valuesSource("form", c.R.PostForm))
+		case "multipart/form-data":
+			if err := c.R.ParseMultipartForm(bindMaxMemory); err != nil {
+				return c.Error(http.StatusBadRequest, "invalid form body")
+			}
+			sources = append(sources, valuesSource("form", c.R.MultipartForm.Value))
+		default:
+			return c.Error(http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType))
+		}
+	}
+	sources = append(sources, valuesSource("query", c.R.URL.Query()))
+	sources = append(sources, bindSource{"path", func(key string) ([]string, bool) {
+		for _, p := range c.P {
+			if p.Key == key {
+				return []string{p.Value}, true
+			}
+		}
+		return nil, false
+	}, func(prefix string) bool {
+		for _, p := range c.P {
+			if strings.HasPrefix(p.Key, prefix) {
+				return true
+			}
+		}
+		return false
+	}})
+
+	for _, src := range sources {
+		bindStruct(v, src, "", map[reflect.Type]bool{}, &errs)
+	}
+	if len(errs) > 0 {
+		return c.Error(http.StatusBadRequest, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// isNestedStruct returns true for struct types that should be binded field by field.
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// bindStruct binds the values from src to the struct's fields and returns true if any values were found.
+// Nil pointers to nested structs are only allocated when any values were found for their fields.
+// The parents keeps track of the struct types on the current path, so recursive types can't recurse forever.
+func bindStruct(v reflect.Value, src bindSource, prefix string, parents map[reflect.Type]bool, errs *[]string) bool {
+	found := false
+	t := v.Type()
+	if !parents[t] {
+		parents[t] = true
+		defer delete(parents, t)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // Unexported field
+		}
+		name := f.Tag.Get(src.tag)
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		ptr := f.Type.Kind() == reflect.Ptr && isNestedStruct(f.Type.Elem())
+		if isNestedStruct(f.Type) || ptr {
+			p := prefix
+			if name != "" {
+				p += name + "."
+			}
+			if !ptr {
+				found = bindStruct(fv, src, p, parents, errs) || found
+				continue
+			}
+			if !fv.CanSet() {
+				continue // Embedded pointer to an unexported struct
+			}
+			if name == "" && parents[f.Type.Elem()] {
+				continue // Recursive type without a new prefix, which would bind the same keys over and over
+			}
+			if p != "" && !src.prefixed(p) {
+				continue // No values for this struct, which also ends recursive types with prefixes
+			}
+			nv := fv
+			if fv.IsNil() {
+				nv = reflect.New(f.Type.Elem())
+			}
+			if bindStruct(nv.Elem(), src, p, parents, errs) {
+				fv.Set(nv)
+				found = true
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		values, ok := src.lookup(prefix + name)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := bindField(fv, values, f.Tag.Get("layout")); err != nil {
+			*errs = append(*errs, fmt.Sprintf("invalid %s value for %q: %s", src.tag, prefix+name, err))
+		}
+	}
+	return found
+}
+
+func bindField(v reflect.Value, values []string, layout string) error {
+	switch {
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return bindField(v.Elem(), values, layout)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 &&
+		!reflect.PtrTo(v.Type()).Implements(textUnmarshalerType):
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, val := range values {
+			if err := bindField(s.Index(i), []string{val}, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return bindValue(v, values[0], layout)
+}
+
+func bindValue(v reflect.Value, s string, layout string) error {
+	if v.Type() == timeType {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return errors.New("expected time with layout " + layout)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return errors.New("invalid value")
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice: // []byte
+		v.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("expected bool")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("expected int")
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("expected uint")
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.New("expected float")
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+type bindAddress struct {
+	City string `form:"city" json:"city"`
+	Zip  int    `form:"zip" json:"zip"`
+}
+
+type bindUser struct {
+	ID      UUID        `path:"id"`
+	Page    int         `query:"page"`
+	Tags    []string    `query:"tag"`
+	Active  *bool       `query:"active"`
+	Since   time.Time   `query:"since" layout:"2006-01-02"`
+	Name    string      `form:"name" json:"name"`
+	Age     uint8       `form:"age" json:"age"`
+	Score   float64     `form:"score" json:"score"`
+	Address bindAddress `form:"address" json:"address"`
+	Ignored string
+}
+
+func TestBind(t *testing.T) {
+	m := testMux(t, "POST", "/users/:id", func(c *Context) error {
+		var u bindUser
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		active := "nil"
+		if u.Active != nil {
+			active = fmt.Sprint(*u.Active)
+		}
+		return c.String(200, fmt.Sprintf("%s %d %v %s %s %s %d %v %s %d", u.ID, u.Page, u.Tags, active,
+			u.Since.Format("2006-01-02"), u.Name, u.Age, u.Score, u.Address.City, u.Address.Zip))
+	})
+
+	var multi bytes.Buffer
+	mw := multipart.NewWriter(&multi)
+	_ = mw.WriteField("name", "bob")
+	_ = mw.WriteField("age", "30")
+	_ = mw.WriteField("address.city", "oslo")
+	_ = mw.Close()
+
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{"/users/" + id + "?page=2&tag=a&tag=b&active=true&since=2020-01-02", "", "", 200,
+			id + " 2 [a b] true 2020-01-02  0 0  0"},
+		{"/users/" + id, "application/json", `{"name":"bob","age":30,"score":1.5,"address":{"city":"oslo","zip":123}}`,
+			200, id + " 0 [] nil 0001-01-01 bob 30 1.5 oslo 123"},
+		{"/users/" + id, "application/x-www-form-urlencoded", "name=bob&age=30&score=1.5&address.city=oslo&address.zip=123",
+			200, id + " 0 [] nil 0001-01-01 bob 30 1.5 oslo 123"},
+		{"/users/" + id, mw.FormDataContentType(), multi.String(),
+			200, id + " 0 [] nil 0001-01-01 bob 30 0 oslo 0"},
+		{"/users/bad?page=x&since=2020", "application/x-www-form-urlencoded", "age=300&address.zip=abc", 400,
+			`invalid form value for "age": expected uint; invalid form value for "address.zip": expected int; ` +
+				`invalid query value for "page": expected int; ` +
+				`invalid query value for "since": expected time with layout 2006-01-02; ` +
+				`invalid path value for "id": invalid value` + "\n"},
+		{"/users/" + id, "application/json", `{"name":`, 400, "invalid json body: unexpected EOF\n"},
+		{"/users/" + id, "text/xml", "<user/>", http.StatusUnsupportedMediaType, "Unsupported Media Type\n"},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Content-Type", tt.contentType)
+		resp := assert.DoRequest(t, m, "POST", tt.path, h, strings.NewReader(tt.body))
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.want)
+	}
+
+	t.Run("pointer to struct", func(t *testing.T) {
+		var dst struct {
+			Billing  *bindAddress `form:"billing"`
+			Shipping *bindAddress `form:"shipping"`
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader("billing.city=oslo&billing.zip=123"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		c := &Context{R: req}
+		if err := c.Bind(&dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Billing == nil || *dst.Billing != (bindAddress{"oslo", 123}) {
+			t.Errorf("got billing address %+v, wanted it to be binded", dst.Billing)
+		}
+		if dst.Shipping != nil {
+			t.Errorf("got shipping address %+v, wanted nil", dst.Shipping)
+		}
+	})
+
+	t.Run("recursive type", func(t *testing.T) {
+		type node struct {
+			Name   string `query:"name"`
+			Next   *node  `query:"next"`
+			Parent *node
+		}
+		var dst node
+		c := &Context{R: httptest.NewRequest("GET", "/?name=a&next.name=b&next.next.name=c", nil)}
+		if err := c.Bind(&dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Name != "a" || dst.Next == nil || dst.Next.Name != "b" || dst.Next.Next == nil ||
+			dst.Next.Next.Name != "c" || dst.Next.Next.Next != nil || dst.Parent != nil {
+			t.Errorf("got %+v, wanted a list of three nodes", dst)
+		}
+	})
+
+	t.Run("invalid destination", func(t *testing.T) {
+		c := &Context{R: &http.Request{}}
+		var s string
+		if err := c.Bind(&s); err == nil {
+			t.Errorf("expected error for non-struct destination")
+		}
+	})
+}
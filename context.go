@@ -1,15 +1,16 @@
 package web
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"io"
+	"io/fs"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 )
 
@@ -35,7 +36,17 @@ type Context struct {
 
 	W http.ResponseWriter
 	R *http.Request
-	P httprouter.Params
+	P Params
+	// Route is the matched route for the request, or nil if no route was found.
+	Route *Route
+
+	params  Params                      // Reusable buffer for P
+	values  map[interface{}]interface{} // Values set by SetValue(), cleared between requests
+	allowed []string                    // Allowed methods for the "405 method not allowed" and OPTIONS handlers
+
+	uploads   []*UploadedFile // Files from FormFiles(), removed between requests
+	uploaded  bool
+	uploadErr error // First error from FormFiles(), returned by later calls
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -43,22 +54,35 @@ type Context struct {
 
 func (m *Mux) newContext() interface{} {
 	return &Context{
-		M: m,
+		M:      m,
+		params: make(Params, 0, m.loadTable().router.maxParams),
 	}
 }
 
-func (m *Mux) getContext(w http.ResponseWriter, r *http.Request, p httprouter.Params) *Context {
+func (m *Mux) getContext(w http.ResponseWriter, r *http.Request, p Params) *Context {
 	c := m.contextPool.Get().(*Context)
 	c.W = w
 	c.R = r
 	c.P = p
+	if p == nil {
+		c.P = c.params[:0]
+	}
 	return c
 }
 
 func (m *Mux) putContext(c *Context) {
+	if cap(c.P) > cap(c.params) {
+		c.params = c.P[:0]
+	}
 	c.W = nil
 	c.R = nil
 	c.P = nil
+	c.Route = nil
+	c.allowed = nil
+	for k := range c.values {
+		delete(c.values, k)
+	}
+	c.removeUploads()
 	m.contextPool.Put(c)
 }
 
@@ -79,15 +103,74 @@ func (c *Context) GetHeader(key string) string {
 	return c.R.Header.Get(key)
 }
 
+// Key is a typed key for storing values on a Context, see SetValue() and Value().
+// Each key created by NewKey() is unique, even if the names are the same, so there's no risk of collisions between
+// different packages.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new Key for values of type T. The name is only used for debugging.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// String returns the name of the key.
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+// SetValue stores a value for the current request, so it can be passed on from a middleware to the next handlers.
+// The values are cleared when the request is done.
+func SetValue[T any](c *Context, key *Key[T], value T) {
+	if c.values == nil {
+		c.values = make(map[interface{}]interface{})
+	}
+	c.values[key] = value
+}
+
+// Value returns a value set by SetValue(), or false if it wasn't found.
+func Value[T any](c *Context, key *Key[T]) (T, bool) {
+	v, found := c.values[key].(T)
+	return v, found
+}
+
+// Subdomain returns the subdomain part of the request's host, when the Mux was registered for a wildcard host (like
+// "*.example.com", see Mux.Host()). For all other hosts it returns an empty string.
+func (c *Context) Subdomain() string {
+	if !isWildcardHost(c.M.host) {
+		return ""
+	}
+	return strings.TrimSuffix(cleanHost(c.R.Host), c.M.host[1:])
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // GetParams is a shortcut to get URL params, first one given by key.
-// See https://pkg.go.dev/github.com/julienschmidt/httprouter#Param for more info.
+// See Mux.Register() for more info.
 // It's safe to call when *Context.P == nil
 func (c *Context) GetParams(key string) string {
 	return c.P.ByName(key)
 }
 
+// Deadline returns the time when the request's context will be cancelled, if there's any deadline set (see
+// RouteOptions.Timeout).
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.R.Context().Deadline()
+}
+
+// AllowedMethods returns a list of the http methods allowed for the request's URL. It's only available for the
+// "405 method not allowed" and OPTIONS handlers (see MuxOptions), for all other handlers it returns nil.
+func (c *Context) AllowedMethods() []string {
+	return append([]string(nil), c.allowed...)
+}
+
+// URL is a shortcut to build a new URL path for a named route.
+// See Mux.URL() for more info.
+func (c *Context) URL(name string, params ...interface{}) (string, error) {
+	return c.M.URL(name, params...)
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // Error returns an *Error to the client, with http response "status" and "msg" body.
@@ -146,55 +229,47 @@ func (c *Context) Stream(status int, r io.Reader) error {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// Stolen from https://pkg.go.dev/net/http#example-FileServer-DotFileHiding
-func hasDotPrefix(path string) bool {
-	parts := strings.Split(path, "/")
-	for _, part := range parts {
-		if strings.HasPrefix(part, ".") {
-			return true
-		}
-	}
-	return false
+// ErrNotSupported is returned (wrapped, use errors.Is()) when the response writer doesn't support an optional feature,
+// like flushing.
+var ErrNotSupported = http.ErrNotSupported
+
+// Flush sends any buffered data to the client, if the response writer (or any writer wrapped by it, see
+// http.ResponseController) supports it. Otherwise it returns ErrNotSupported.
+func (c *Context) Flush() error {
+	return http.NewResponseController(c.W).Flush()
+}
+
+// SetWriteDeadline sets a new deadline for writing the response, overriding the http.Server's WriteTimeout. A zero
+// time means no deadline. It returns ErrNotSupported if the response writer can't set a deadline.
+func (c *Context) SetWriteDeadline(t time.Time) error {
+	return http.NewResponseController(c.W).SetWriteDeadline(t)
+}
+
+// Hijack lets the caller take over the client's connection, like when upgrading to a websocket connection. After a
+// call to Hijack the response writer must not be used anymore. It returns ErrNotSupported if the response writer
+// can't be hijacked (like for HTTP/2 connections).
+func (c *Context) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(c.W).Hijack()
 }
 
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
 // File attemps to send the contents of a file, located at `fp` and opened using `fs`.
 // Default behaviour:
 // * File path will be cleaned and resolved
-// * Dotfiles will be ignored (or not, optionally)
-// * Directory listnings will be ignored (or optionally serve an index file instead)
+// * Dotfiles will be ignored
+// * Directories will be ignored (see FileWithOptions() for serving index files or listings instead)
 // * Any paths ignored will serve a `NotFound()` response instead
 // * Content-Type will be autodetected (see `http.ServeContent` for more info and other extra handling)
 //
 // Warning: if http.Server timeouts are set too short, this write might time out.
-// TODO: replace http.FileSystem with io/fs.FS when go1.16 lands
 func (c *Context) File(fs http.FileSystem, fp string) error {
-	fp = filepath.Clean(fp)
-	// TODO: add option to disable this check
-	if hasDotPrefix(fp) {
-		return c.NotFound()
-	}
-
-	f, err := fs.Open(fp)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			c.Log("Error: %s", err)
-		}
-		return c.NotFound()
-	}
-	defer f.Close()
-
-	fi, err := f.Stat()
-	if err != nil {
-		c.Log("Error: %s", err)
-		return c.NotFound()
-	}
-	if fi.IsDir() {
-		// TODO: add option to try serve an index file instead
-		return c.NotFound()
-	}
+	return c.FileWithOptions(fs, fp, nil)
+}
 
-	http.ServeContent(c.W, c.R, fi.Name(), fi.ModTime(), f)
-	return nil // ServeContent will handle any errors with a http.Error, so we do nothing else
+// FileFS works the same way as File, except the file is opened using a fs.FS (like an embed.FS).
+func (c *Context) FileFS(fsys fs.FS, fp string) error {
+	return c.File(http.FS(fsys), fp)
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////
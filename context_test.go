@@ -113,6 +113,26 @@ func TestDecodeJSON(t *testing.T) {
 	}
 }
 
+func TestContextValues(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	c := m.getContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	user, other := NewKey[string]("user"), NewKey[string]("user")
+	if v, ok := Value(c, user); ok {
+		t.Errorf("got value %q, wanted none", v)
+	}
+	SetValue(c, user, "bob")
+	if v, ok := Value(c, user); !ok || v != "bob" {
+		t.Errorf("got value %q, wanted %q", v, "bob")
+	}
+	if v, ok := Value(c, other); ok {
+		t.Errorf("got value %q for other key with the same name, wanted none", v)
+	}
+	m.putContext(c)
+	if len(c.values) != 0 {
+		t.Errorf("got values %v, wanted them cleared", c.values)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkContextError(b *testing.B) {
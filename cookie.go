@@ -0,0 +1,272 @@
+package web
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cookies
+// For more information, see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies
+
+var (
+	// ErrInvalidCookie is returned when reading a signed or encrypted cookie that has been tampered with, or was
+	// created with an unknown key.
+	ErrInvalidCookie = errors.New("invalid cookie")
+	// ErrExpiredCookie is returned when reading a signed or encrypted cookie that has expired.
+	ErrExpiredCookie = errors.New("expired cookie")
+	// ErrNoCookieKeys is returned when trying to use signed or encrypted cookies, without any MuxOptions.CookieKeys.
+	ErrNoCookieKeys = errors.New("no cookie keys")
+)
+
+// Max size of a cookie's name and value, as most browsers won't accept larger cookies
+const maxCookieSize = 4096
+
+// Min size of the secret keys used for signed and encrypted cookies
+const minCookieKeySize = 32
+
+// CookieOptions contains optional settings for a cookie, with secure defaults.
+type CookieOptions struct {
+	// Path defaults to "/".
+	Path string
+	// Domain defaults to the request's host only.
+	Domain string
+	// MaxAge sets the cookie's lifetime. Defaults to a session cookie, which is removed when the browser is closed.
+	// Signed and encrypted cookies also carry the expiry time inside their values, so that they can't be reused
+	// after they have expired.
+	MaxAge time.Duration
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// AllowScripts allows client side scripts to read the cookie, by not setting the HttpOnly flag.
+	AllowScripts bool
+	// Insecure allows the cookie to be sent over plain HTTP connections, by not setting the Secure flag.
+	Insecure bool
+}
+
+func newCookie(name, value string, opt *CookieOptions) *http.Cookie {
+	if opt == nil {
+		opt = &CookieOptions{}
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opt.Path,
+		Domain:   opt.Domain,
+		SameSite: opt.SameSite,
+		HttpOnly: !opt.AllowScripts,
+		Secure:   !opt.Insecure,
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	if opt.MaxAge > 0 {
+		cookie.MaxAge = int(opt.MaxAge.Seconds())
+		cookie.Expires = time.Now().Add(opt.MaxAge).UTC()
+	}
+	return cookie
+}
+
+// SetCookie sets a cookie in the response, using secure defaults unless overridden by the optional CookieOptions.
+// It returns an error if the cookie's name or value is invalid, or if the cookie is larger than 4kb.
+func (c *Context) SetCookie(name, value string, opt *CookieOptions) error {
+	if len(name)+len(value) > maxCookieSize {
+		return errors.Errorf("cookie too large: %s", name)
+	}
+	if !validCookieValue(value) {
+		return errors.Errorf("invalid cookie: %s", name)
+	}
+	v := newCookie(name, value, opt).String()
+	if v == "" {
+		return errors.Errorf("invalid cookie: %s", name)
+	}
+	c.W.Header().Add("Set-Cookie", v)
+	return nil
+}
+
+// validCookieValue returns true if the value only contains bytes allowed by net/http, which would otherwise silently
+// drop the invalid bytes.
+func validCookieValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if b := v[i]; b < 0x20 || b >= 0x7f || b == '"' || b == ';' || b == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCookie returns the value of a cookie from the request, or http.ErrNoCookie if it wasn't found.
+func (c *Context) GetCookie(name string) (string, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// DeleteCookie removes a cookie from the client. The path and domain in the optional CookieOptions must match the
+// ones used when setting the cookie.
+func (c *Context) DeleteCookie(name string, opt *CookieOptions) {
+	cookie := newCookie(name, "", opt)
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0).UTC()
+	c.W.Header().Add("Set-Cookie", cookie.String())
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// cookieKey contains the keys derived from a secret key in MuxOptions.CookieKeys.
+type cookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// newCookieKeys derives separate signing and encryption keys from each of the secret keys.
+// NOTE: it will cause a panic if any key is too short.
+func newCookieKeys(secrets [][]byte) []cookieKey {
+	keys := make([]cookieKey, len(secrets))
+	for i, s := range secrets {
+		if len(s) < minCookieKeySize {
+			panic("cookie keys must be at least 32 bytes")
+		}
+		block, err := aes.NewCipher(deriveKey(s, "cookie encryption"))
+		if err != nil {
+			panic(err) // Not reached, the derived key is always 32 bytes
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err) // Not reached, GCM is always available for AES
+		}
+		keys[i] = cookieKey{
+			sign: deriveKey(s, "cookie signing"),
+			aead: aead,
+		}
+	}
+	return keys
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// cookiePayload returns the expiry time (as unix seconds, or 0 for no expiry) followed by the value.
+func cookiePayload(value string, opt *CookieOptions) []byte {
+	var expires int64
+	if opt != nil && opt.MaxAge > 0 {
+		expires = time.Now().Add(opt.MaxAge).Unix()
+	}
+	b := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(expires))
+	return append(b, value...)
+}
+
+// parseCookiePayload returns the value from a payload, or an error if it has expired.
+func parseCookiePayload(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", ErrInvalidCookie
+	}
+	expires := int64(binary.BigEndian.Uint64(b))
+	if expires > 0 && time.Now().Unix() >= expires {
+		return "", ErrExpiredCookie
+	}
+	return string(b[8:]), nil
+}
+
+func signCookie(key []byte, name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// SetSignedCookie sets a cookie with a value signed using HMAC-SHA256, so it can be read by the client but any
+// changes to it will be detected by GetSignedCookie(). The cookie is signed with the first key in
+// MuxOptions.CookieKeys. See SetCookie() for more info.
+func (c *Context) SetSignedCookie(name, value string, opt *CookieOptions) error {
+	if len(c.M.cookieKeys) < 1 {
+		return ErrNoCookieKeys
+	}
+	payload := cookiePayload(value, opt)
+	mac := signCookie(c.M.cookieKeys[0].sign, name, payload)
+	return c.SetCookie(name, base64.RawURLEncoding.EncodeToString(append(payload, mac...)), opt)
+}
+
+// GetSignedCookie returns the value of a cookie set by SetSignedCookie(), after verifying it's signature using any of
+// the keys in MuxOptions.CookieKeys. It returns ErrInvalidCookie if the cookie has been tampered with,
+// ErrExpiredCookie if it has expired, or http.ErrNoCookie if it wasn't found.
+func (c *Context) GetSignedCookie(name string) (string, error) {
+	if len(c.M.cookieKeys) < 1 {
+		return "", ErrNoCookieKeys
+	}
+	v, err := c.GetCookie(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil || len(b) < sha256.Size {
+		return "", ErrInvalidCookie
+	}
+	payload, mac := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	for _, k := range c.M.cookieKeys {
+		if hmac.Equal(mac, signCookie(k.sign, name, payload)) {
+			return parseCookiePayload(payload)
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// SetEncryptedCookie sets a cookie with a value encrypted using AES-GCM, so it can't be read or changed by the
+// client. The cookie is encrypted with the first key in MuxOptions.CookieKeys. See SetCookie() for more info.
+func (c *Context) SetEncryptedCookie(name, value string, opt *CookieOptions) error {
+	if len(c.M.cookieKeys) < 1 {
+		return ErrNoCookieKeys
+	}
+	aead := c.M.cookieKeys[0].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "generating nonce")
+	}
+	b := aead.Seal(nonce, nonce, cookiePayload(value, opt), []byte(name))
+	return c.SetCookie(name, base64.RawURLEncoding.EncodeToString(b), opt)
+}
+
+// GetEncryptedCookie returns the decrypted value of a cookie set by SetEncryptedCookie(), trying each of the keys in
+// MuxOptions.CookieKeys. It returns ErrInvalidCookie if the cookie has been tampered with, ErrExpiredCookie if it
+// has expired, or http.ErrNoCookie if it wasn't found.
+func (c *Context) GetEncryptedCookie(name string) (string, error) {
+	if len(c.M.cookieKeys) < 1 {
+		return "", ErrNoCookieKeys
+	}
+	v, err := c.GetCookie(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, k := range c.M.cookieKeys {
+		ns := k.aead.NonceSize()
+		if len(b) < ns {
+			break
+		}
+		payload, err := k.aead.Open(nil, b[:ns], b[ns:], []byte(name))
+		if err == nil {
+			return parseCookiePayload(payload)
+		}
+	}
+	return "", ErrInvalidCookie
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestCookie(t *testing.T) {
+	m := testMux(t, "GET", "/", func(c *Context) error {
+		if err := c.SetCookie("a", "1", nil); err != nil {
+			return err
+		}
+		if err := c.SetCookie("b", "2", &CookieOptions{Path: "/b", MaxAge: time.Hour, AllowScripts: true,
+			Insecure: true, SameSite: http.SameSiteStrictMode}); err != nil {
+			return err
+		}
+		c.DeleteCookie("old", nil)
+		v, err := c.GetCookie("in")
+		if err != nil {
+			return err
+		}
+		return c.String(200, v)
+	})
+	h := http.Header{}
+	h.Set("Cookie", "in=hello")
+	resp := assert.DoRequest(t, m, "GET", "/", h, nil)
+	assert.StatusCode(t, resp, 200)
+	assert.Body(t, resp, "hello")
+
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies) != 3 {
+		t.Fatalf("got cookies %q, wanted 3", cookies)
+	}
+	if want := "a=1; Path=/; HttpOnly; Secure; SameSite=Lax"; cookies[0] != want {
+		t.Errorf("got cookie %q, wanted %q", cookies[0], want)
+	}
+	if !strings.HasPrefix(cookies[1], "b=2; Path=/b; Expires=") || !strings.HasSuffix(cookies[1],
+		"; Max-Age=3600; SameSite=Strict") {
+		t.Errorf("got cookie %q", cookies[1])
+	}
+	if !strings.Contains(cookies[2], "old=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Max-Age=0") {
+		t.Errorf("got cookie %q", cookies[2])
+	}
+
+	resp = assert.DoRequest(t, m, "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusInternalServerError)
+
+	t.Run("invalid", func(t *testing.T) {
+		c := &Context{W: httptest.NewRecorder()}
+		for _, tt := range [][2]string{{"", "v"}, {"a b", "v"}, {"a", "v;x"}, {"a", "\"v\""},
+			{"a", strings.Repeat("v", maxCookieSize)}} {
+			if err := c.SetCookie(tt[0], tt[1], nil); err == nil {
+				t.Errorf("expected error for cookie %q=%q", tt[0], tt[1])
+			}
+		}
+	})
+}
+
+func newCookieMux(t *testing.T, keys ...string) *Mux {
+	opt := &MuxOptions{}
+	for _, k := range keys {
+		opt.CookieKeys = append(opt.CookieKeys, []byte(strings.Repeat(k, minCookieKeySize)))
+	}
+	m := NewMux(opt)
+	m.Register("GET", "/set", func(c *Context) error {
+		opt := &CookieOptions{MaxAge: time.Hour}
+		if c.R.URL.Query().Get("expired") != "" {
+			opt.MaxAge = time.Nanosecond
+		}
+		if err := c.SetSignedCookie("signed", "hello world", opt); err != nil {
+			return err
+		}
+		return c.SetEncryptedCookie("secret", "hello world", opt)
+	})
+	m.Register("GET", "/get", func(c *Context) error {
+		signed, err := c.GetSignedCookie("signed")
+		if err != nil {
+			return c.Error(400, err.Error())
+		}
+		secret, err := c.GetEncryptedCookie("secret")
+		if err != nil {
+			return c.Error(400, err.Error())
+		}
+		return c.String(200, signed+","+secret)
+	})
+	return m
+}
+
+// roundtrip returns the cookies set by a response, for a new request.
+func roundtrip(t *testing.T, m *Mux, path string, edit func(*http.Cookie)) http.Header {
+	t.Helper()
+	resp := assert.DoRequest(t, m, "GET", path, nil, nil)
+	assert.StatusCode(t, resp, 200)
+	h := http.Header{}
+	for _, c := range resp.Cookies() {
+		if edit != nil {
+			edit(c)
+		}
+		h.Add("Cookie", c.Name+"="+c.Value)
+	}
+	return h
+}
+
+func TestSignedCookies(t *testing.T) {
+	m := newCookieMux(t, "a")
+	h := roundtrip(t, m, "/set", nil)
+	if strings.Contains(h.Get("Cookie"), "hello") {
+		t.Errorf("got cookies in plain text: %q", h.Get("Cookie"))
+	}
+	resp := assert.DoRequest(t, m, "GET", "/get", h, nil)
+	assert.StatusCode(t, resp, 200)
+	assert.Body(t, resp, "hello world,hello world")
+
+	t.Run("key rotation", func(t *testing.T) {
+		rotated := newCookieMux(t, "b", "a")
+		resp := assert.DoRequest(t, rotated, "GET", "/get", h, nil)
+		assert.StatusCode(t, resp, 200)
+
+		removed := newCookieMux(t, "b")
+		resp = assert.DoRequest(t, removed, "GET", "/get", h, nil)
+		assert.StatusCode(t, resp, 400)
+		assert.Body(t, resp, "invalid cookie\n")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		for _, name := range []string{"signed", "secret"} {
+			h := roundtrip(t, m, "/set", func(c *http.Cookie) {
+				if c.Name == name {
+					b := []byte(c.Value)
+					b[10] ^= 1
+					c.Value = string(b)
+				}
+			})
+			resp := assert.DoRequest(t, m, "GET", "/get", h, nil)
+			assert.StatusCode(t, resp, 400)
+			assert.Body(t, resp, "invalid cookie\n")
+		}
+	})
+
+	t.Run("swapped names", func(t *testing.T) {
+		h := roundtrip(t, m, "/set", func(c *http.Cookie) {
+			c.Name = map[string]string{"signed": "secret", "secret": "signed"}[c.Name]
+		})
+		resp := assert.DoRequest(t, m, "GET", "/get", h, nil)
+		assert.StatusCode(t, resp, 400)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		h := roundtrip(t, m, "/set?expired=1", nil)
+		time.Sleep(time.Second)
+		resp := assert.DoRequest(t, m, "GET", "/get", h, nil)
+		assert.StatusCode(t, resp, 400)
+		assert.Body(t, resp, "expired cookie\n")
+	})
+
+	t.Run("missing keys", func(t *testing.T) {
+		c := &Context{M: NewMux(nil), W: httptest.NewRecorder()}
+		if err := c.SetSignedCookie("a", "b", nil); err != ErrNoCookieKeys {
+			t.Errorf("got error %v, wanted %v", err, ErrNoCookieKeys)
+		}
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for short key")
+			}
+		}()
+		NewMux(&MuxOptions{CookieKeys: [][]byte{bytes.Repeat([]byte("a"), 16)}})
+	})
+}
@@ -1,8 +1,5 @@
 module github.com/lmas/web
 
-go 1.15
+go 1.20
 
-require (
-	github.com/julienschmidt/httprouter v1.3.0
-	github.com/pkg/errors v0.9.1
-)
+require github.com/pkg/errors v0.9.1
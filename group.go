@@ -0,0 +1,120 @@
+package web
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+// Group is a collection of handlers registered under a common URL prefix, sharing a common list of middlewares.
+// Groups can be nested to any depth and each sub group inherits the prefix and middlewares from it's parents.
+type Group struct {
+	mux    *Mux
+	parent *Group
+	prefix string
+	mw     []Middleware
+}
+
+// Group returns a new Group that registers all it's handlers under the URL prefix.
+// You can optionally use middlewares too, they will be added to all handlers registered with the group.
+func (m *Mux) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		mux:    m,
+		prefix: prefix,
+		mw:     append([]Middleware(nil), mw...), // Copied, so Use() won't modify the caller's slice
+	}
+}
+
+// Group returns a new sub group that registers all it's handlers under the URL prefix, appended to the prefix of
+// the parent group. Middlewares from the parent groups will be run before the optional middlewares for this group.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		mux:    g.mux,
+		parent: g,
+		prefix: prefix,
+		mw:     append([]Middleware(nil), mw...), // Copied, so Use() won't modify the caller's slice
+	}
+}
+
+// Use adds one or more middlewares to the group.
+// NOTE: only handlers registered after this call will use the new middlewares.
+func (g *Group) Use(mw ...Middleware) {
+	g.mw = append(g.mw, mw...)
+}
+
+// path returns the full URL path for url, by joining it with the prefixes from all parent groups.
+func (g *Group) path(url string) string {
+	if g.parent == nil {
+		return path.Join(g.prefix, url)
+	}
+	return g.parent.path(path.Join(g.prefix, url))
+}
+
+// middlewares returns the full list of middlewares for this group, starting with the parent's middlewares.
+func (g *Group) middlewares(mw ...Middleware) []Middleware {
+	var list []Middleware
+	if g.parent != nil {
+		list = g.parent.middlewares()
+	}
+	list = append(list, g.mw...)
+	return append(list, mw...)
+}
+
+// Register registers a new handler for a certain http method and URL, under the group's prefix.
+// You can optionally use middlewares too, they will be executed after the group's middlewares.
+func (g *Group) Register(method, url string, handler Handler, mw ...Middleware) *Route {
+	return g.mux.Register(method, g.path(url), handler, g.middlewares(mw...)...)
+}
+
+// RegisterWithOptions works the same way as Register, except it uses the optional settings for the route, under the
+// group's prefix. See Mux.RegisterWithOptions() for more info.
+func (g *Group) RegisterWithOptions(method, url string, handler Handler, opt *RouteOptions,
+	mw ...Middleware) *Route {
+	return g.mux.RegisterWithOptions(method, g.path(url), handler, opt, g.middlewares(mw...)...)
+}
+
+// File is a helper to serve a simple http GET response for a single file, under the group's prefix.
+// See Mux.File() for more info.
+func (g *Group) File(url, file string, mw ...Middleware) *Route {
+	return g.Register("GET", url, fileHandler(file), mw...)
+}
+
+// FileWithOptions works the same way as File, except the file is opened using fs and served using the optional
+// settings, under the group's prefix. See Mux.FileWithOptions() for more info.
+func (g *Group) FileWithOptions(url string, fs http.FileSystem, file string, opt *StaticOptions,
+	mw ...Middleware) *Route {
+	return g.Register("GET", url, fileOptionsHandler(fs, file, opt), mw...)
+}
+
+// Static is a helper to serve a whole directory with static files, under the group's prefix.
+// See Mux.Static() for more info.
+func (g *Group) Static(dir string, fs http.FileSystem, mw ...Middleware) *Route {
+	return g.Register("GET", path.Join(dir, "/*filepath"), staticHandler(fs, nil), mw...)
+}
+
+// StaticWithOptions works the same way as Static, except it uses the optional settings for serving the files, under
+// the group's prefix. See Mux.StaticWithOptions() for more info.
+func (g *Group) StaticWithOptions(dir string, fs http.FileSystem, opt *StaticOptions, mw ...Middleware) *Route {
+	if opt == nil {
+		opt = &StaticOptions{}
+	}
+	return g.Register("GET", path.Join(dir, "/*filepath"), staticHandler(fs, opt), mw...)
+}
+
+// FileFS works the same way as File, except the file is opened using a fs.FS.
+// See Mux.FileFS() for more info.
+func (g *Group) FileFS(url string, fsys fs.FS, file string, mw ...Middleware) *Route {
+	return g.Register("GET", url, fileFSHandler(fsys, file), mw...)
+}
+
+// StaticFS works the same way as Static, except the files are opened using a fs.FS.
+// See Mux.StaticFS() for more info.
+func (g *Group) StaticFS(dir string, fsys fs.FS, mw ...Middleware) *Route {
+	return g.Static(dir, http.FS(fsys), mw...)
+}
+
+// Mount registers a http.Handler to handle all requests under a URL prefix, under the group's prefix.
+// See Mux.Mount() for more info.
+func (g *Group) Mount(prefix string, h http.Handler, mw ...Middleware) {
+	g.mux.Mount(g.path(prefix), h, g.middlewares(mw...)...)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestGroup(t *testing.T) {
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return Handler(func(c *Context) error {
+				c.R.Header.Add("X-MW", name)
+				return next(c)
+			})
+		}
+	}
+	hello := func(c *Context) error {
+		return c.String(200, strings.Join(c.R.Header.Values("X-MW"), ","))
+	}
+
+	t.Run("simple group", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		g := m.Group("/api", tag("api"))
+		g.Register("GET", "/hello", hello)
+		resp := assert.DoRequest(t, m, "GET", "/api/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "api")
+	})
+	t.Run("nested groups", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		api := m.Group("/api", tag("api"))
+		v1 := api.Group("/v1", tag("v1"))
+		admin := v1.Group("/admin", tag("admin"))
+		admin.Register("GET", "/hello", hello, tag("route"))
+		v1.Register("GET", "/hello", hello)
+
+		resp := assert.DoRequest(t, m, "GET", "/api/v1/admin/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "api,v1,admin,route")
+
+		resp = assert.DoRequest(t, m, "GET", "/api/v1/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "api,v1")
+	})
+	t.Run("use middleware", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		api := m.Group("/api")
+		api.Register("GET", "/before", hello)
+		sub := api.Group("/sub")
+		api.Use(tag("used"))
+		api.Register("GET", "/after", hello)
+		sub.Register("GET", "/hello", hello)
+
+		resp := assert.DoRequest(t, m, "GET", "/api/before", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.BodyEmpty(t, resp)
+
+		resp = assert.DoRequest(t, m, "GET", "/api/after", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "used")
+
+		resp = assert.DoRequest(t, m, "GET", "/api/sub/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "used")
+	})
+	t.Run("shared middlewares", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		shared := make([]Middleware, 1, 2) // Spare capacity, so an append could overwrite the other group's list
+		shared[0] = tag("shared")
+		a := m.Group("/a", shared...)
+		b := m.Group("/b", shared...)
+		a.Use(tag("a"))
+		b.Use(tag("b"))
+		a.Register("GET", "/hello", hello)
+		b.Register("GET", "/hello", hello)
+
+		resp := assert.DoRequest(t, m, "GET", "/a/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "shared,a")
+		resp = assert.DoRequest(t, m, "GET", "/b/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "shared,b")
+	})
+	t.Run("static files", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		g := m.Group("/api").Group("/files")
+		g.Static("/static", http.Dir("."))
+		resp := assert.DoRequest(t, m, "GET", "/api/files/static/group.go", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		resp = assert.DoRequest(t, m, "GET", "/api/files/static/missing.go", nil, nil)
+		assert.StatusCode(t, resp, http.StatusNotFound)
+	})
+}
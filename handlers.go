@@ -1,7 +1,9 @@
 package web
 
 import (
+	"html/template"
 	"net/http"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -19,16 +21,118 @@ func SimpleNotFoundHandler(c *Context) error {
 	return nil
 }
 
+// SimpleMethodNotAllowedHandler is the default "405 method not allowed" handler. It simply calls http.Error(), the
+// "Allow" header has already been set with the allowed methods.
+func SimpleMethodNotAllowedHandler(c *Context) error {
+	http.Error(c.W, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return nil
+}
+
+// SimpleOptionsHandler is the default handler for OPTIONS requests. It sends an empty response, the "Allow" header
+// has already been set with the allowed methods.
+func SimpleOptionsHandler(c *Context) error {
+	return c.Empty(http.StatusOK)
+}
+
+// FieldErrors is implemented by errors that contains error messages for individual fields, like validate.Errors.
+type FieldErrors interface {
+	error
+	FieldErrors() map[string][]string
+}
+
 // SimpleErrorHandler is the default handler for handling handler errors (that sounded sick).
-// It checks if an error is a Error and sends it's status code and msg as the http response. If it's not, it simply
-// sends an "500 internal server error" for all other errors.
+// It checks if an error is a Error (or a Problem) and sends it's status code and msg as the http response. FieldErrors
+// are sent as a "422 unprocessable entity" JSON response, with a map of the fields and their error messages. If it's
+// neither, it simply sends an "500 internal server error" for all other errors.
 func SimpleErrorHandler(c *Context, err error) error {
 	switch e := errors.Cause(err).(type) {
 	case *Error:
 		http.Error(c.W, e.Error(), e.Status())
 		return nil // Don't wanna log client errors
+	case *Problem:
+		msg := e.title()
+		if e.Detail != "" {
+			msg = e.Detail
+		}
+		http.Error(c.W, msg, e.status())
+		if e.status() < 500 {
+			return nil
+		}
+		return err
+	case FieldErrors:
+		return c.JSON(http.StatusUnprocessableEntity, e.FieldErrors())
 	default:
 		http.Error(c.W, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return err
 	}
 }
+
+// WrapHTTPHandler returns a Handler that calls a http.Handler, with the response writer and request from the Context.
+func WrapHTTPHandler(h http.Handler) Handler {
+	return func(c *Context) error {
+		h.ServeHTTP(c.W, c.R)
+		return nil
+	}
+}
+
+// HTTPHandler returns a http.Handler that runs a Handler, using the Mux's global middlewares and error handling.
+// You can optionally use middlewares too, the same way as in Register().
+func (m *Mux) HTTPHandler(h Handler, mw ...Middleware) http.Handler {
+	wrapped := m.wrap(h, mw...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.run(wrapped, w, r, nil)
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var routesTemplate = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Routes</title></head>
+<body>
+<table>
+<tr><th>Host</th><th>Method</th><th>Path</th><th>Name</th><th>Middlewares</th><th>Source</th></tr>
+{{- range .}}
+<tr><td>{{.Host}}</td><td>{{.Method}}</td><td>{{.Path}}</td><td>{{.Name}}</td><td>{{range $i, $mw := .Middlewares}}{{if $i}}, {{end}}{{$mw}}{{end}}</td><td>{{.Source}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type routeInfo struct {
+	Host        string   `json:"host,omitempty"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Name        string   `json:"name,omitempty"`
+	Middlewares []string `json:"middlewares"`
+	Source      string   `json:"source"`
+}
+
+// RoutesHandler is a debug handler that shows a table with all routes registered on the Mux (see Mux.Routes()). The
+// table is rendered as HTML by default, or as JSON if the request accepts "application/json" or has the query
+// "?format=json".
+// NOTE: it's meant to be used during development only, as it might leak sensitive info about your source code.
+func RoutesHandler(c *Context) error {
+	var list []routeInfo
+	for _, r := range c.M.Routes() {
+		list = append(list, routeInfo{
+			Host:        r.Host(),
+			Method:      r.Method(),
+			Path:        r.Path(),
+			Name:        r.Name(),
+			Middlewares: r.Middlewares(),
+			Source:      r.Source(),
+		})
+	}
+	if c.R.URL.Query().Get("format") == "json" || strings.Contains(c.GetHeader("Accept"), "application/json") {
+		return c.JSON(http.StatusOK, list)
+	}
+
+	buff := c.M.getTemplateBuff()
+	defer c.M.putTemplateBuff(buff)
+	if err := routesTemplate.Execute(buff, list); err != nil {
+		return err
+	}
+	return c.HTML(http.StatusOK, buff.String())
+}
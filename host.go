@@ -0,0 +1,90 @@
+package web
+
+import (
+	"sort"
+	"strings"
+)
+
+// hostTable contains the Muxes registered for other hosts. Like the routeTable, a table will never be modified after
+// it has been stored, instead it will be replaced by an updated copy (see Mux.Host()).
+type hostTable struct {
+	hosts     map[string]*Mux
+	wildcards []*Mux // Sorted by the length of their hosts, longest first
+}
+
+// Host returns a new Mux which will handle all requests for a certain host name, instead of the current Mux.
+// The host can contain a leading wildcard, like "*.example.com", to match any subdomains (see Context.Subdomain()).
+// Exact host names are matched before wildcards and longer wildcards are matched before shorter ones. Requests for
+// unknown hosts will fall back to be handled by the current Mux.
+// You can optionally provide a MuxOptions struct with custom settings for the new Mux, the same way as in NewMux().
+// It's safe to register new hosts while the Mux is serving requests.
+// NOTE: it will cause a panic if the host has already been registered, or if it's called on the temporary Mux in
+// ReplaceRoutes().
+func (m *Mux) Host(host string, opt *MuxOptions) *Mux {
+	host = cleanHost(host)
+	if host == "" || host == "*." {
+		panic("invalid host")
+	}
+	if m.temporary {
+		panic("can't register hosts while replacing routes")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ht := &hostTable{hosts: make(map[string]*Mux)}
+	if old := m.loadHosts(); old != nil {
+		if _, found := old.hosts[host]; found {
+			panic("host already registered: " + host)
+		}
+		for h, sub := range old.hosts {
+			ht.hosts[h] = sub
+		}
+		ht.wildcards = append(ht.wildcards, old.wildcards...)
+	}
+
+	sub := NewMux(opt)
+	sub.host = host
+	ht.hosts[host] = sub
+	if isWildcardHost(host) {
+		ht.wildcards = append(ht.wildcards, sub)
+		sort.SliceStable(ht.wildcards, func(i, j int) bool {
+			return len(ht.wildcards[i].host) > len(ht.wildcards[j].host)
+		})
+	}
+	m.hosts.Store(ht)
+	return sub
+}
+
+// loadHosts returns the current host table, or nil if no hosts has been registered.
+func (m *Mux) loadHosts() *hostTable {
+	ht, _ := m.hosts.Load().(*hostTable)
+	return ht
+}
+
+// match returns the Mux registered for the host, or nil if none was found.
+func (ht *hostTable) match(host string) *Mux {
+	host = cleanHost(host)
+	if sub, found := ht.hosts[host]; found && !isWildcardHost(host) {
+		return sub
+	}
+	for _, sub := range ht.wildcards {
+		suffix := sub.host[1:] // Keeps the dot, so "example.com" won't match "*.example.com"
+		if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return sub
+		}
+	}
+	return nil
+}
+
+func isWildcardHost(host string) bool {
+	return strings.HasPrefix(host, "*.")
+}
+
+// cleanHost strips any port number and trailing dot from a host and then lowercases it.
+func cleanHost(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(host)
+}
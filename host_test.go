@@ -0,0 +1,120 @@
+package web
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func doHostRequest(t *testing.T, m *Mux, host, path string) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Host = host
+	m.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestHost(t *testing.T) {
+	hello := func(msg string) Handler {
+		return func(c *Context) error {
+			return c.String(200, msg+c.Subdomain())
+		}
+	}
+	m := testMux(t, "GET", "/", hello("default"))
+	m.Host("example.com", nil).Register("GET", "/", hello("example"))
+	m.Host("*.example.com", nil).Register("GET", "/", hello("wildcard "))
+	m.Host("*.api.example.com", nil).Register("GET", "/", hello("api "))
+	m.Host("other.com", &MuxOptions{
+		HandleNotFound: func(c *Context) error {
+			return c.String(http.StatusNotFound, "other not found")
+		},
+	})
+
+	tests := []struct {
+		host   string
+		status int
+		body   string
+	}{
+		{"unknown.com", 200, "default"},
+		{"example.com", 200, "example"},
+		{"EXAMPLE.com:8080", 200, "example"},
+		{"example.com.", 200, "example"},
+		{"www.example.com", 200, "wildcard www"},
+		{"a.b.example.com:443", 200, "wildcard a.b"},
+		{"v1.api.example.com", 200, "api v1"},
+		{"notexample.com", 200, "default"},
+		{"other.com", 404, "other not found"},
+	}
+	for _, tt := range tests {
+		resp := doHostRequest(t, m, tt.host, "/")
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+	}
+
+	t.Run("duplicate host", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for duplicate host")
+			}
+		}()
+		m.Host("Example.com", nil)
+	})
+}
+
+func TestHostTemplates(t *testing.T) {
+	tmpl := template.Must(template.New("page.html").Funcs(templateFuncs(nil)).Parse(`{{urlfor "home"}}`))
+	templates := map[string]*template.Template{"page.html": tmpl}
+	render := func(c *Context) error {
+		return c.Render(200, "page.html", nil)
+	}
+	m := NewMux(nil)
+	m.Host("a.com", &MuxOptions{Templates: templates}).Register("GET", "/a", render).Named("home")
+	m.Host("b.com", &MuxOptions{Templates: templates}).Register("GET", "/b", render).Named("home")
+
+	for host, want := range map[string]string{"a.com": "/a", "b.com": "/b"} {
+		resp := doHostRequest(t, m, host, want)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, want)
+	}
+	if err := tmpl.Execute(ioutil.Discard, nil); err == nil {
+		t.Errorf("expected the caller's templates to still use the placeholder funcs")
+	}
+}
+
+func TestHostConcurrent(t *testing.T) {
+	m := testMux(t, "GET", "/", func(c *Context) error {
+		return c.String(200, "default")
+	})
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					doHostRequest(t, m, "www.example.com", "/")
+					m.Routes()
+				}
+			}
+		}()
+	}
+	for _, h := range []string{"a.com", "*.example.com", "b.com", "*.b.com"} {
+		m.Host(h, nil)
+	}
+	close(done)
+	wg.Wait()
+
+	if got := len(m.loadHosts().hosts); got != 4 {
+		t.Errorf("got %d hosts, wanted 4", got)
+	}
+}
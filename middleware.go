@@ -1,7 +1,9 @@
 package web
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/pkg/errors"
 )
@@ -32,12 +34,17 @@ func (m *Mux) recoverErrors(next Handler) Handler {
 
 // wrap a Handler in one or more Middlewares.
 func (m *Mux) wrap(h Handler, mw ...Middleware) Handler {
-	h = m.recoverErrors(h)
-	mw = append(m.opt.Middlewares, mw...)
-	if len(mw) < 1 {
-		return h
-	}
+	return chain(m.recoverErrors(h), append(append([]Middleware(nil), m.opt.Middlewares...), mw...))
+}
+
+// wrapRoute wraps a route's Handler in it's middlewares, the route's options and lastly the global middlewares.
+func (m *Mux) wrapRoute(r *Route, h Handler, mw []Middleware) Handler {
+	h = r.withOptions(chain(m.recoverErrors(h), mw))
+	return chain(h, m.opt.Middlewares)
+}
 
+// chain wraps a Handler in a list of Middlewares, with the first middleware in the list being executed first.
+func chain(h Handler, mw []Middleware) Handler {
 	for i := len(mw) - 1; i >= 0; i-- {
 		if mw[i] == nil {
 			panic("Trying to use a nil pointer as middleware")
@@ -46,3 +53,45 @@ func (m *Mux) wrap(h Handler, mw ...Middleware) Handler {
 	}
 	return h
 }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type httpCallKey struct{}
+
+// httpCall keeps track of the Context and next Handler, while a request passes through a http middleware.
+type httpCall struct {
+	c    *Context
+	next Handler
+	err  error
+}
+
+// WrapHTTPMiddleware returns a Middleware that runs a standard http middleware, of the form
+// func(http.Handler) http.Handler. Any changes the http middleware makes to the response writer or request will be
+// passed on to the next Handler.
+func WrapHTTPMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Context().Value(httpCallKey{}).(*httpCall)
+		call.c.W, call.c.R = w, r
+		call.err = call.next(call.c)
+	}))
+	return func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			w, r := c.W, c.R
+			call := &httpCall{c: c, next: next}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpCallKey{}, call)))
+			c.W, c.R = w, r
+			return call.err
+		})
+	}
+}
+
+// HTTPMiddleware returns a standard http middleware, of the form func(http.Handler) http.Handler, that runs a
+// Middleware. Any errors returned by the Middleware will be handled by the Mux's error handler.
+func (m *Mux) HTTPMiddleware(mw Middleware) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := m.recoverErrors(mw(WrapHTTPHandler(next)))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.run(h, w, r, nil)
+		})
+	}
+}
@@ -58,6 +58,64 @@ func TestMiddleware(t *testing.T) {
 	})
 }
 
+func TestHTTPMiddleware(t *testing.T) {
+	stdMW := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("deny") != "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("X-STD", "std")
+			r.Header.Set("X-MSG", "hello")
+			next.ServeHTTP(w, r)
+		})
+	}
+	webMW := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			if c.R.URL.Query().Get("fail") != "" {
+				return c.Error(http.StatusTeapot, "teapot")
+			}
+			return next(c)
+		})
+	}
+
+	t.Run("wrap http middleware", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		m.Register("GET", "/hello", func(c *Context) error {
+			return c.String(200, c.GetHeader("X-MSG"))
+		}, WrapHTTPMiddleware(stdMW))
+		m.Register("GET", "/error", func(c *Context) error {
+			return c.Error(http.StatusTeapot, "teapot")
+		}, WrapHTTPMiddleware(stdMW))
+
+		resp := assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "X-STD", "std")
+		assert.Body(t, resp, "hello")
+
+		resp = assert.DoRequest(t, m, "GET", "/hello?deny=1", nil, nil)
+		assert.StatusCode(t, resp, http.StatusForbidden)
+
+		resp = assert.DoRequest(t, m, "GET", "/error", nil, nil)
+		assert.StatusCode(t, resp, http.StatusTeapot)
+		assert.Body(t, resp, "teapot\n")
+	})
+	t.Run("http middleware", func(t *testing.T) {
+		m := testMux(t, "", "", nil)
+		h := m.HTTPMiddleware(webMW)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}))
+
+		resp := assert.DoRequest(t, h, "GET", "/", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "ok")
+
+		resp = assert.DoRequest(t, h, "GET", "/?fail=1", nil, nil)
+		assert.StatusCode(t, resp, http.StatusTeapot)
+		assert.Body(t, resp, "teapot\n")
+	})
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkMiddleware(b *testing.B) {
@@ -1,7 +1,9 @@
 package middlewares
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -27,6 +29,22 @@ func (w *recorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the wrapped ResponseWriter, so http.ResponseController (and web.Context) can find any optional
+// interfaces it implements, like http.Flusher.
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements the http.Hijacker interface, if the wrapped ResponseWriter supports it. The response will be
+// logged with a "101 switching protocols" status.
+func (w *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
 func (w *recorder) Status() string {
 	return strconv.Itoa(w.status)
 }
@@ -51,6 +69,8 @@ func (w *recorder) Bytes() string {
 // Response run time
 // HTTP Referer
 // Client User-Agent
+//
+// Requests for routes with the "accesslog" meta tag set to "off" won't be logged (see web.RouteOptions).
 func AccessLog(l *log.Logger) func(web.Handler) web.Handler {
 	if l == nil {
 		// There's no point trying to run this middleware without providing a logger, hence the hard panic
@@ -58,6 +78,9 @@ func AccessLog(l *log.Logger) func(web.Handler) web.Handler {
 	}
 	return func(next web.Handler) web.Handler {
 		return web.Handler(func(c *web.Context) error {
+			if c.Route.Meta("accesslog") == "off" {
+				return next(c)
+			}
 			c.W = &recorder{c.W, http.StatusOK, 0}
 			start := time.Now()
 			err := next(c)
@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"bytes"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -29,7 +30,7 @@ func BenchmarkAccessLog(b *testing.B) {
 	wrapped := mw(benchHandler)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest("GET", "/", nil)
-	c := &web.Context{nil, w, r, nil}
+	c := &web.Context{W: w, R: r}
 
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -37,3 +38,53 @@ func BenchmarkAccessLog(b *testing.B) {
 		wrapped(c)
 	}
 }
+
+func TestAccessLogSkip(t *testing.T) {
+	var buf bytes.Buffer
+	m := web.NewMux(&web.MuxOptions{
+		Middlewares: []web.Middleware{AccessLog(log.New(&buf, "", 0))},
+	})
+	m.RegisterWithOptions("GET", "/", basicHandler, &web.RouteOptions{
+		Meta: map[string]string{"accesslog": "off"},
+	})
+
+	resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	if len(buf.String()) > 0 {
+		t.Errorf("got unexpected log line: %q", buf.String())
+	}
+}
+
+func TestAccessLogFlush(t *testing.T) {
+	var flushErr error
+	wrapped := AccessLog(log.New(&bytes.Buffer{}, "", 0))(func(c *web.Context) error {
+		flushErr = c.Flush()
+		return nil
+	})
+	// Hides the httptest.ResponseRecorder's Flush method
+	w := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	c := &web.Context{W: w, R: httptest.NewRequest("GET", "/", nil)}
+	if err := wrapped(c); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(flushErr, web.ErrNotSupported) {
+		t.Errorf("got error %v, wanted %v", flushErr, web.ErrNotSupported)
+	}
+}
+
+func TestAccessLogEventStream(t *testing.T) {
+	var buf bytes.Buffer
+	mw := AccessLog(log.New(&buf, "", 0))
+	wrapped := mw(func(c *web.Context) error {
+		s, err := c.EventStream(nil)
+		if err != nil {
+			return err
+		}
+		return s.Send(web.Event{Data: "hello"})
+	})
+
+	resp := doRequest(t, wrapped, "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Header(t, resp, "Content-Type", "text/event-stream; charset=utf-8")
+	assert.Body(t, resp, "data: hello\n\n")
+}
@@ -37,8 +37,18 @@ func singleBasicAuth(defaultUser, defaultPass string) checkFunc {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// Context key for the authenticated user
+var basicAuthUserKey = web.NewKey[string]("middlewares.basicauth.user")
+
+// BasicAuthUser returns the username that was authenticated by the BasicAuth middleware, or an empty string if the
+// middleware wasn't used.
+func BasicAuthUser(c *web.Context) string {
+	user, _ := web.Value(c, basicAuthUserKey)
+	return user
+}
+
 // BasicAuth is a middleware that checks requests for the HTTP Basic Auth header and securely validates it against the
-// given user/password pair.
+// given user/password pair. The authenticated user is available with BasicAuthUser().
 func BasicAuth(username, password string) func(web.Handler) web.Handler {
 	isValid := singleBasicAuth(username, password)
 	return func(next web.Handler) web.Handler {
@@ -49,6 +59,7 @@ func BasicAuth(username, password string) func(web.Handler) web.Handler {
 				return c.Error(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
 			}
 			// 's all good ya'll
+			web.SetValue(c, basicAuthUserKey, user)
 			return next(c)
 		})
 	}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/lmas/web"
 	"github.com/lmas/web/internal/assert"
 )
 
@@ -26,6 +27,14 @@ func TestBasicAuth(t *testing.T) {
 		assert.StatusCode(t, resp, http.StatusOK)
 		assert.Body(t, resp, "ok")
 	})
+	t.Run("authenticated user", func(t *testing.T) {
+		h := basic(func(c *web.Context) error {
+			return c.String(200, BasicAuthUser(c))
+		})
+		resp := doRequest(t, h, "GET", "/", headers, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, user)
+	})
 	t.Run("missing auth header", func(t *testing.T) {
 		resp := doRequest(t, wrapped, "GET", "/", nil, nil)
 		assert.StatusCode(t, resp, http.StatusUnauthorized)
@@ -24,7 +24,7 @@ func doRequest(t *testing.T, handler web.Handler, method, path string, headers h
 	if headers != nil {
 		req.Header = headers
 	}
-	c := &web.Context{nil, rec, req, nil}
+	c := &web.Context{W: rec, R: req}
 	if err := handler(c); err != nil {
 		_ = web.SimpleErrorHandler(c, err)
 	}
@@ -2,14 +2,16 @@ package web
 
 import (
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
-
-	"github.com/julienschmidt/httprouter"
+	"sync/atomic"
 )
 
 // RegisterFunc is a function signature used when you want to register multiple handlers under a common URL path.
@@ -20,25 +22,50 @@ type RegisterFunc func(string, string, Handler)
 type MuxOptions struct {
 	// Simple logger
 	Log *log.Logger
-	// Templates that can be rendered using context.Render()
+	// Templates that can be rendered using context.Render(). The templates will be cloned and the "urlfor" func in
+	// the clones will be set to use Mux.URL().
 	Templates map[string]*template.Template
+	// Assets is used by the "asset" (see AssetManifest.URL()), "assetSRI" (see AssetManifest.Integrity()) and
+	// "assetTag" (see AssetManifest.Tag()) funcs in the templates. When set, these funcs will replace any custom
+	// funcs with the same names in the templates. Use StaticOptions.Assets for serving the fingerprinted paths.
+	Assets *AssetManifest
 	// HandleNotFound is a Handler that will be called for '404 not found" errors. If not set it will default to
 	// the SimpleNotFoundHandler() handler.
 	HandleNotFound Handler
+	// HandleMethodNotAllowed is a Handler that will be called for "405 method not allowed" errors, when a
+	// request's method isn't registered for the URL but other methods are. If not set it will default to the
+	// SimpleMethodNotAllowedHandler() handler.
+	HandleMethodNotAllowed Handler
+	// HandleOptions is a Handler that will be called for OPTIONS requests, for URLs without a registered OPTIONS
+	// handler. If not set it will default to the SimpleOptionsHandler() handler.
+	HandleOptions Handler
 	// HandleError is a ErrorHandler that will be called for all errors returned from a Handler (except for
 	// "404 not found"). It defaults to SimpleErrorHandler().
 	HandleError ErrorHandler
 	// Middlewares is a list of middlewares that will be globaly added to all handlers
 	Middlewares []Middleware
+	// Encoders is a list of encoders used by Context.Respond(), in order of preference. It defaults to
+	// DefaultEncoders().
+	Encoders []Encoder
+	// CookieKeys is a list of secret keys (of at least 32 random bytes each) used for signed and encrypted cookies.
+	// New cookies always use the first key, while the other keys are only used for reading older cookies. This
+	// allows for key rotation, by adding a new key first and removing the oldest key after the cookies has expired.
+	CookieKeys [][]byte
 }
 
 // Mux implements the http.Handler interface and allows you to easily register handlers and middleware with sane
-// defaults. It uses github.com/julienschmidt/httprouter, for quick and easy routing.
+// defaults. It uses a radix tree based router, for quick and easy routing (see Register() for more info).
 type Mux struct {
-	mux          *httprouter.Router
+	mu           sync.Mutex   // Protects updates of the route and host tables
+	table        atomic.Value // Holds a *routeTable
+	hosts        atomic.Value // Holds a *hostTable, if any hosts has been registered
+	serving      int32        // Set to 1 when the Mux has started serving requests
 	opt          *MuxOptions
+	host         string
+	temporary    bool // Set for the temporary Mux used by ReplaceRoutes()
 	contextPool  sync.Pool
 	templatePool sync.Pool
+	cookieKeys   []cookieKey
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -54,29 +81,72 @@ func NewMux(opt *MuxOptions) *Mux {
 	if opt.HandleNotFound == nil {
 		opt.HandleNotFound = SimpleNotFoundHandler
 	}
+	if opt.HandleMethodNotAllowed == nil {
+		opt.HandleMethodNotAllowed = SimpleMethodNotAllowedHandler
+	}
+	if opt.HandleOptions == nil {
+		opt.HandleOptions = SimpleOptionsHandler
+	}
 	if opt.HandleError == nil {
 		opt.HandleError = SimpleErrorHandler
 	}
+	if opt.Encoders == nil {
+		opt.Encoders = DefaultEncoders()
+	}
 
 	m := &Mux{
-		opt: opt,
+		opt:        opt,
+		cookieKeys: newCookieKeys(opt.CookieKeys),
 	}
 	m.contextPool.New = m.newContext
 	m.templatePool.New = m.newTemplateBuff
-	m.mux = &httprouter.Router{
-		RedirectTrailingSlash:  true,
-		RedirectFixedPath:      true,
-		HandleMethodNotAllowed: true,
-		HandleOPTIONS:          true,
+	m.table.Store(newRouteTable())
+
+	funcs := template.FuncMap{
+		"urlfor": m.URL,
+	}
+	if opt.Assets != nil {
+		for k, v := range opt.Assets.templateFuncs() {
+			funcs[k] = v
+		}
+	}
+	// Clone the templates, so the funcs can be bound to this Mux without affecting any other Mux using the same
+	// templates (like when using Host())
+	templates := make(map[string]*template.Template, len(opt.Templates))
+	for name, t := range opt.Templates {
+		clone, err := t.Clone()
+		if err != nil {
+			panic(err)
+		}
+		templates[name] = clone.Funcs(funcs)
+	}
+	if opt.Templates != nil {
+		opt.Templates = templates
 	}
 
 	opt.HandleNotFound = m.wrap(opt.HandleNotFound)
-	m.mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		m.run(opt.HandleNotFound, w, r, nil)
-	})
+	opt.HandleMethodNotAllowed = m.wrap(opt.HandleMethodNotAllowed)
+	opt.HandleOptions = m.wrap(opt.HandleOptions)
 	return m
 }
 
+func (m *Mux) loadTable() *routeTable {
+	return m.table.Load().(*routeTable)
+}
+
+// updateTable runs fn with the current route table and stores the returned table, while holding a lock.
+// Once the Mux has started serving requests, fn will get a copy of the table instead, so that in-flight requests
+// can keep using the old table without any races.
+func (m *Mux) updateTable(fn func(*routeTable) *routeTable) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.loadTable()
+	if atomic.LoadInt32(&m.serving) == 1 {
+		t = t.filter(func(*Route) bool { return true })
+	}
+	m.table.Store(fn(t))
+}
+
 func (m *Mux) log(msg string, args ...interface{}) {
 	if m.opt.Log == nil {
 		return
@@ -86,8 +156,11 @@ func (m *Mux) log(msg string, args ...interface{}) {
 	}
 }
 
-func (m *Mux) run(h Handler, w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	c := m.getContext(w, r, p)
+func (m *Mux) run(h Handler, w http.ResponseWriter, r *http.Request, p Params) {
+	m.runContext(h, m.getContext(w, r, p))
+}
+
+func (m *Mux) runContext(h Handler, c *Context) {
 	err := h(c)
 	m.putContext(c)
 	if err != nil {
@@ -98,18 +171,136 @@ func (m *Mux) run(h Handler, w http.ResponseWriter, r *http.Request, p httproute
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // ServeHTTP implements the http.Handler interface.
+//
+// If no route was found for a request, but there's a route for the same path with or without a trailing slash, or
+// for the cleaned and case insensitive path, the client will be redirected to that path instead. If there's routes
+// for the path using other methods, the "405 method not allowed" (or OPTIONS) handler will be called instead of the
+// "404 not found" handler.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	m.mux.ServeHTTP(w, r)
+	if ht := m.loadHosts(); ht != nil {
+		if sub := ht.match(r.Host); sub != nil {
+			sub.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if atomic.LoadInt32(&m.serving) == 0 {
+		// Wait for any unfinished table updates
+		m.mu.Lock()
+		atomic.StoreInt32(&m.serving, 1)
+		m.mu.Unlock()
+	}
+
+	t := m.loadTable()
+	c := m.getContext(w, r, nil)
+	p := r.URL.Path
+	if route := t.router.lookup(r.Method, p, &c.P); route != nil {
+		c.Route = route
+		m.runContext(route.handler, c)
+		return
+	}
+
+	if fixed, ok := t.router.redirect(r.Method, p); ok && fixed != p {
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet {
+			code = http.StatusTemporaryRedirect
+		}
+		u := *r.URL
+		u.Path, u.RawPath = fixed, ""
+		http.Redirect(w, r, u.String(), code)
+		m.putContext(c)
+		return
+	}
+
+	if allow := t.router.allowed(p, r.Method); allow != nil {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		c.allowed = allow
+		if r.Method == http.MethodOptions {
+			m.runContext(m.opt.HandleOptions, c)
+		} else {
+			m.runContext(m.opt.HandleMethodNotAllowed, c)
+		}
+		return
+	}
+	m.runContext(m.opt.HandleNotFound, c)
 }
 
 // Register registers a new handler for a certain http method and URL. It will also handle any errors returned from the
 // handler, by responding to the erroring request with http.Error().
 // You can optionally use one or more http.Handler middleware. First middleware in the list will be executed first, and
 // then it loops forward through all middlewares and lasty executes the request handler last.
-func (m *Mux) Register(method, url string, handler Handler, mw ...Middleware) {
-	wrapped := m.wrap(handler, mw...)
-	m.mux.Handle(method, url, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		m.run(wrapped, w, r, p)
+// The returned Route can be used to give the route a name. Use RegisterWithOptions() for setting optional settings.
+// It's safe to register new routes while the Mux is serving requests.
+//
+// URLs can contain ":param" parts, which matches a single path segment, and a "*catchall" part at the end, which
+// matches the rest of the path. Param values are available with Context.GetParams().
+// Routes are allowed to overlap and static paths are matched first, then params and lastly catchalls. For example
+// "/users/new" will be matched before "/users/:id", which will be matched before "/users/*path".
+//
+// URL params can optionally use a constraint, like "/users/:id<int>", and any requests with params that doesn't match
+// the constraint will try the other overlapping routes instead (like "/users/:name<slug>" or "/users/*path"), before
+// getting a '404 not found' response. Params with constraints are matched before params without any. Available
+// constraints are "int", "uuid", "slug" and "alpha". Any other constraint will be used as a regexp, like
+// "/posts/:year<[0-9]{4}>" (a regexp can't contain slashes though).
+func (m *Mux) Register(method, url string, handler Handler, mw ...Middleware) *Route {
+	return m.RegisterWithOptions(method, url, handler, nil, mw...)
+}
+
+// RegisterWithOptions works the same way as Register, except it uses the optional settings for the route (see
+// RouteOptions). The options are applied after the global middlewares (see MuxOptions), but before the route's
+// middlewares.
+func (m *Mux) RegisterWithOptions(method, url string, handler Handler, opt *RouteOptions,
+	mw ...Middleware) *Route {
+	route := newRoute(m, method, url, opt, append(append([]Middleware(nil), m.opt.Middlewares...), mw...))
+	route.handler = m.wrapRoute(route, handler, mw)
+	m.updateTable(func(t *routeTable) *routeTable {
+		t.add(route)
+		return t
+	})
+	return route
+}
+
+// Remove removes a registered route, for a certain http method and URL (the same URL used in Register()).
+// It returns false if no route was found.
+// It's safe to call while the Mux is serving requests and any in-flight requests will be unaffected.
+func (m *Mux) Remove(method, url string) bool {
+	found := false
+	m.updateTable(func(t *routeTable) *routeTable {
+		return t.filter(func(r *Route) bool {
+			if r.method == method && r.path == url {
+				found = true
+				return false
+			}
+			return true
+		})
+	})
+	return found
+}
+
+// ReplaceRoutes atomically replaces all registered routes with the new routes registered by fn. The Mux given to
+// fn is a temporary Mux, using the same MuxOptions, and only the routes registered on it will be kept (any hosts
+// registered with Mux.Host() are unaffected and new hosts can't be registered on the temporary Mux).
+// It's safe to call while the Mux is serving requests and any in-flight requests will be unaffected.
+// NOTE: if fn causes a panic, the old routes will be kept.
+func (m *Mux) ReplaceRoutes(fn func(*Mux)) {
+	tmp := &Mux{
+		opt:        m.opt,
+		host:       m.host,
+		temporary:  true,
+		cookieKeys: m.cookieKeys,
+	}
+	// Any handlers created by the temporary Mux (like with HTTPHandler()) will use Contexts for the real Mux
+	tmp.contextPool.New = m.newContext
+	tmp.templatePool.New = m.newTemplateBuff
+	tmp.table.Store(newRouteTable())
+	fn(tmp)
+
+	m.updateTable(func(*routeTable) *routeTable {
+		t := tmp.loadTable()
+		for _, r := range t.routes {
+			r.mux = m
+		}
+		return t
 	})
 }
 
@@ -122,25 +313,123 @@ func (m *Mux) RegisterPrefix(prefix string, mw ...Middleware) RegisterFunc {
 	}
 }
 
+// mountMethods is the list of http methods that will be passed on to a mounted http.Handler.
+var mountMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// Mount registers a http.Handler to handle all requests, for all http methods, under a URL prefix. The prefix will
+// be stripped from the request's URL path before it's passed on to the handler.
+// This allows you to use any other http.Handler (like net/http/pprof or another Mux) inside the Mux, and it will
+// still use the global middlewares and error handling.
+// You can optionally use middlewares too, the same way as in Register().
+func (m *Mux) Mount(prefix string, h http.Handler, mw ...Middleware) {
+	prefix = path.Join("/", prefix)
+	handler := mountHandler(prefix, h)
+	for _, method := range mountMethods {
+		if prefix != "/" {
+			m.Register(method, prefix, handler, mw...)
+		}
+		m.Register(method, path.Join(prefix, "/*mountpath"), handler, mw...)
+	}
+}
+
+func mountHandler(prefix string, h http.Handler) Handler {
+	return func(c *Context) error {
+		r := new(http.Request)
+		*r = *c.R
+		r.URL = new(url.URL)
+		*r.URL = *c.R.URL
+		r.URL.Path = "/" + strings.TrimPrefix(c.GetParams("mountpath"), "/")
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.RawPath, prefix), "/")
+		}
+		h.ServeHTTP(c.W, r)
+		return nil
+	}
+}
+
 // File is a helper to serve a simple http GET response for a single file. If the file "disappears" while the server is
 // running, a 404 Not found will be returned.
 // NOTE: if the file doesn't exist at start up, it will cause a panic instead.
 // You can optionally use middlewares too, the same way as in Register().
-func (m *Mux) File(url, file string, mw ...Middleware) {
+// See FileWithOptions() for serving precompressed files or setting a Cache-Control header.
+func (m *Mux) File(url, file string, mw ...Middleware) *Route {
+	return m.Register("GET", url, fileHandler(file), mw...)
+}
+
+func fileHandler(file string) Handler {
 	if _, err := os.Stat(file); os.IsNotExist(err) {
 		panic("file doesn't exist: " + file)
 	}
 	fs := http.Dir(filepath.Dir(file))
-	m.Register("GET", url, func(c *Context) error {
-		return c.File(fs, file)
-	}, mw...)
+	name := filepath.Base(file)
+	return func(c *Context) error {
+		return c.File(fs, name)
+	}
+}
+
+// FileWithOptions works the same way as File, except the file is opened using fs and served using the optional
+// settings (see StaticOptions), like for serving precompressed files or setting a Cache-Control header. Use http.Dir()
+// for a file on disk or http.FS() for a file in a fs.FS.
+// NOTE: if the file doesn't exist in fs, it will cause a panic.
+func (m *Mux) FileWithOptions(url string, fs http.FileSystem, file string, opt *StaticOptions,
+	mw ...Middleware) *Route {
+	return m.Register("GET", url, fileOptionsHandler(fs, file, opt), mw...)
+}
+
+func fileOptionsHandler(fs http.FileSystem, file string, opt *StaticOptions) Handler {
+	f, err := fs.Open(file)
+	if err != nil {
+		panic("file doesn't exist: " + file)
+	}
+	f.Close()
+	if opt == nil {
+		opt = &StaticOptions{}
+	}
+	return func(c *Context) error {
+		return c.FileWithOptions(fs, file, opt)
+	}
+}
+
+// FileFS works the same way as File, except the file is opened using a fs.FS (like an embed.FS).
+// NOTE: if the file doesn't exist in fsys, it will cause a panic.
+func (m *Mux) FileFS(url string, fsys fs.FS, file string, mw ...Middleware) *Route {
+	return m.Register("GET", url, fileFSHandler(fsys, file), mw...)
+}
+
+func fileFSHandler(fsys fs.FS, file string) Handler {
+	if _, err := fs.Stat(fsys, file); err != nil {
+		panic("file doesn't exist: " + file)
+	}
+	hfs := http.FS(fsys)
+	return func(c *Context) error {
+		return c.File(hfs, file)
+	}
 }
 
 // Static is a helper to serve a whole directory with static files.
 // You can optionally use middlewares too, the same way as in Register().
-func (m *Mux) Static(dir string, fs http.FileSystem, mw ...Middleware) {
-	m.Register("GET", path.Join(dir, "/*filepath"), func(c *Context) error {
-		fp := httprouter.CleanPath(c.GetParams("filepath")) // Not sure if it's already cleaned but oh well...
-		return c.File(fs, fp)
-	}, mw...)
+func (m *Mux) Static(dir string, fs http.FileSystem, mw ...Middleware) *Route {
+	return m.Register("GET", path.Join(dir, "/*filepath"), staticHandler(fs, nil), mw...)
+}
+
+// StaticWithOptions works the same way as Static, except it uses the optional settings for serving the files (see
+// StaticOptions). Use http.FS() to serve files from a fs.FS.
+func (m *Mux) StaticWithOptions(dir string, fs http.FileSystem, opt *StaticOptions, mw ...Middleware) *Route {
+	if opt == nil {
+		opt = &StaticOptions{}
+	}
+	return m.Register("GET", path.Join(dir, "/*filepath"), staticHandler(fs, opt), mw...)
+}
+
+// StaticFS works the same way as Static, except the files are opened using a fs.FS (like an embed.FS).
+// Use fs.Sub() if the files are inside a sub directory of fsys.
+func (m *Mux) StaticFS(dir string, fsys fs.FS, mw ...Middleware) *Route {
+	return m.Static(dir, http.FS(fsys), mw...)
+}
+
+func staticHandler(fs http.FileSystem, opt *StaticOptions) Handler {
+	return func(c *Context) error {
+		fp := cleanPath(c.GetParams("filepath"))
+		return c.FileWithOptions(fs, fp, opt)
+	}
 }
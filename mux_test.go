@@ -1,10 +1,14 @@
 package web
 
 import (
+	"embed"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"github.com/lmas/web/internal/assert"
 )
@@ -123,6 +127,283 @@ func TestRegisterPrefix(t *testing.T) {
 	})
 }
 
+func TestMethodNotAllowed(t *testing.T) {
+	mw := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			c.SetHeader("X-MW", "global")
+			return next(c)
+		})
+	}
+	hello := func(c *Context) error {
+		return c.String(200, "hello")
+	}
+
+	t.Run("default handlers", func(t *testing.T) {
+		m := testMux(t, "GET", "/hello", hello)
+		m.Register("POST", "/hello", hello)
+		resp := assert.DoRequest(t, m, "PUT", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusMethodNotAllowed)
+		assert.Header(t, resp, "Allow", "GET, OPTIONS, POST")
+		assert.Body(t, resp, http.StatusText(http.StatusMethodNotAllowed)+"\n")
+
+		resp = assert.DoRequest(t, m, "OPTIONS", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Allow", "GET, OPTIONS, POST")
+		assert.BodyEmpty(t, resp)
+	})
+	t.Run("custom handlers", func(t *testing.T) {
+		m := NewMux(&MuxOptions{
+			Middlewares: []Middleware{mw},
+			HandleMethodNotAllowed: func(c *Context) error {
+				return c.JSON(http.StatusMethodNotAllowed, map[string][]string{
+					"allowed": c.AllowedMethods(),
+				})
+			},
+			HandleOptions: func(c *Context) error {
+				return fmt.Errorf("err")
+			},
+		})
+		m.Register("GET", "/hello", hello)
+		resp := assert.DoRequest(t, m, "DELETE", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusMethodNotAllowed)
+		assert.Header(t, resp, "Allow", "GET, OPTIONS")
+		assert.Header(t, resp, "X-MW", "global")
+		assert.Body(t, resp, `{"allowed":["GET","OPTIONS"]}`+"\n")
+
+		resp = assert.DoRequest(t, m, "OPTIONS", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusInternalServerError)
+		assert.Header(t, resp, "X-MW", "global")
+
+		m.Register("GET", "/allow", func(c *Context) error {
+			c.SetHeader("Allow", "GET")
+			return c.String(200, fmt.Sprint(c.AllowedMethods() == nil))
+		})
+		resp = assert.DoRequest(t, m, "GET", "/allow", nil, nil)
+		assert.Body(t, resp, "true")
+	})
+}
+
+func TestMount(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("test")
+		}
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	})
+	mw := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			c.SetHeader("X-MW", "global")
+			return next(c)
+		})
+	}
+	m := NewMux(&MuxOptions{
+		Middlewares: []Middleware{mw},
+	})
+	m.Mount("/admin", echo)
+	m.Group("/api").Mount("/v1", echo)
+	sub := NewMux(nil)
+	sub.Register("GET", "/hello", func(c *Context) error {
+		return c.String(200, "hello from sub")
+	})
+	m.Mount("/sub", sub)
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/admin", 200, "GET /"},
+		{"GET", "/admin/", 200, "GET /"},
+		{"POST", "/admin/users/1", 200, "POST /users/1"},
+		{"DELETE", "/api/v1/items", 200, "DELETE /items"},
+		{"GET", "/sub/hello", 200, "hello from sub"},
+		{"GET", "/sub/missing", 404, "404 page not found\n"},
+		{"GET", "/admin/panic", 500, http.StatusText(http.StatusInternalServerError) + "\n"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, tt.method, tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Header(t, resp, "X-MW", "global")
+		assert.Body(t, resp, tt.body)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	h := m.HTTPHandler(func(c *Context) error {
+		return c.Error(http.StatusTeapot, "teapot")
+	})
+	resp := assert.DoRequest(t, h, "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusTeapot)
+	assert.Body(t, resp, "teapot\n")
+
+	resp = assert.DoRequest(t, m.HTTPHandler(WrapHTTPHandler(http.NotFoundHandler())), "GET", "/", nil, nil)
+	assert.StatusCode(t, resp, http.StatusNotFound)
+}
+
+func TestHotSwap(t *testing.T) {
+	hello := func(msg string) Handler {
+		return func(c *Context) error {
+			return c.String(200, msg)
+		}
+	}
+
+	t.Run("remove route", func(t *testing.T) {
+		m := testMux(t, "GET", "/hello", hello("hello"))
+		m.Register("GET", "/other", hello("other")).Named("other")
+		resp := assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+
+		if !m.Remove("GET", "/hello") {
+			t.Errorf("expected route to be removed")
+		}
+		if m.Remove("GET", "/hello") {
+			t.Errorf("expected route to be missing")
+		}
+		resp = assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.StatusCode(t, resp, http.StatusNotFound)
+		resp = assert.DoRequest(t, m, "GET", "/other", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		if url, err := m.URL("other"); err != nil || url != "/other" {
+			t.Errorf("got URL %q and error %q, wanted %q", url, err, "/other")
+		}
+	})
+	t.Run("replace routes", func(t *testing.T) {
+		m := testMux(t, "GET", "/hello", hello("hello"))
+		resp := assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.Body(t, resp, "hello")
+
+		m.ReplaceRoutes(func(tmp *Mux) {
+			tmp.Register("GET", "/hello", hello("replaced")).Named("hello")
+			tmp.Group("/api").Register("GET", "/items", hello("items"))
+		})
+		resp = assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.Body(t, resp, "replaced")
+		resp = assert.DoRequest(t, m, "GET", "/api/items", nil, nil)
+		assert.Body(t, resp, "items")
+		if len(m.Routes()) != 2 {
+			t.Errorf("got %d routes, wanted 2", len(m.Routes()))
+		}
+		m.Register("GET", "/hello2", hello("hello2")).Named("hello2")
+		if url, err := m.URL("hello2"); err != nil || url != "/hello2" {
+			t.Errorf("got URL %q and error %q, wanted %q", url, err, "/hello2")
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for duplicate route")
+				}
+			}()
+			m.ReplaceRoutes(func(tmp *Mux) {
+				tmp.Register("GET", "/dup", hello("dup"))
+				tmp.Register("GET", "/dup", hello("dup"))
+			})
+		}()
+		resp = assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+		assert.Body(t, resp, "replaced")
+
+		m.ReplaceRoutes(func(tmp *Mux) {
+			tmp.Mount("/http", tmp.HTTPHandler(hello("http")))
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected panic for host")
+					}
+				}()
+				tmp.Host("example.com", nil)
+			}()
+		})
+		resp = assert.DoRequest(t, m, "GET", "/http", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "http")
+	})
+	t.Run("concurrent requests", func(t *testing.T) {
+		// Run with the -race flag to check for any races
+		m := testMux(t, "GET", "/static", hello("static"))
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					rec := httptest.NewRecorder()
+					m.ServeHTTP(rec, httptest.NewRequest("GET", "/static", nil))
+					if rec.Code != http.StatusOK {
+						t.Errorf("got status code %d, wanted %d", rec.Code, http.StatusOK)
+					}
+					rec = httptest.NewRecorder()
+					m.ServeHTTP(rec, httptest.NewRequest("GET", "/dynamic/1", nil))
+					if rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+						t.Errorf("got status code %d for dynamic route", rec.Code)
+					}
+				}
+			}()
+		}
+		for i := 0; i < 100; i++ {
+			m.Register("GET", "/dynamic/:id", hello("dynamic")).Named("dynamic")
+			m.Remove("GET", "/dynamic/:id")
+			m.ReplaceRoutes(func(tmp *Mux) {
+				tmp.Register("GET", "/static", hello("static"))
+			})
+		}
+		close(done)
+		wg.Wait()
+	})
+}
+
+//go:embed testdata/static
+var testStatic embed.FS
+
+func TestStaticFS(t *testing.T) {
+	static, err := fs.Sub(testStatic, "testdata/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMux(nil)
+	m.StaticFS("/static", static)
+	m.FileFS("/robots.txt", fstest.MapFS{"robots.txt": {Data: []byte("User-agent: *")}}, "robots.txt")
+	m.Group("/api").StaticFS("/docs", fstest.MapFS{
+		"index.txt":   {Data: []byte("docs")},
+		".git/config": {Data: []byte("secret")},
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/static/hello.txt", 200, "hello world\n"},
+		{"/static/css/app.css", 200, "body{color:red}\n"},
+		{"/static/css", 404, "404 page not found\n"},
+		{"/static/missing.txt", 404, "404 page not found\n"},
+		{"/robots.txt", 200, "User-agent: *"},
+		{"/api/docs/index.txt", 200, "docs"},
+		{"/api/docs/.git/config", 404, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+	}
+
+	resp := assert.DoRequest(t, m, "GET", "/static/css/app.css", nil, nil)
+	assert.Header(t, resp, "Content-Type", "text/css; charset=utf-8")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for missing file")
+		}
+	}()
+	m.FileFS("/missing", fstest.MapFS{}, "missing.txt")
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkMux(b *testing.B) {
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Constraints that can be used for path params, like "/users/:id<int>". Any other constraint will be compiled as a
+// regexp instead, like "/posts/:year<[0-9]{4}>".
+var paramConstraints = map[string]*regexp.Regexp{
+	"int":   regexp.MustCompile(`^-?[0-9]+$`),
+	"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+	"slug":  regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`),
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`),
+}
+
+// paramConstraint is a constraint for a single path param.
+type paramConstraint struct {
+	key string
+	re  *regexp.Regexp
+}
+
+// parsePattern strips all constraints from a route's path pattern and returns the cleaned path, a list of the param
+// keys and a list of the constraints. It will cause a panic for invalid paths or constraints.
+func parsePattern(pattern string) (string, []string, []paramConstraint) {
+	if len(pattern) < 1 || pattern[0] != '/' {
+		panic("path must begin with '/' in path: " + pattern)
+	}
+	var b strings.Builder
+	var keys []string
+	var list []paramConstraint
+	p := pattern
+	for len(p) > 0 {
+		start := strings.IndexAny(p, ":*")
+		if start < 0 {
+			b.WriteString(p)
+			break
+		}
+		end := strings.IndexByte(p[start:], '/')
+		if end < 0 {
+			end = len(p)
+		} else {
+			end += start
+		}
+		b.WriteString(p[:start])
+		seg := p[start:end]
+		p = p[end:]
+		if seg[0] == '*' && (p != "" || pattern[len(pattern)-len(seg)-1] != '/') {
+			panic("catchall params are only allowed at the end of the path, after a '/', in path: " + pattern)
+		}
+
+		key, i := seg[1:], strings.IndexByte(seg, '<')
+		if i >= 0 {
+			if seg[0] == '*' {
+				panic("catchall params can't use constraints in path: " + pattern)
+			}
+			if seg[len(seg)-1] != '>' || i == len(seg)-2 {
+				panic("invalid param constraint in path: " + pattern)
+			}
+			key = seg[1:i]
+			expr := seg[i+1 : len(seg)-1]
+			re, found := paramConstraints[expr]
+			if !found {
+				re = regexp.MustCompile("^(?:" + expr + ")$")
+			}
+			list = append(list, paramConstraint{key, re})
+			seg = seg[:i]
+		}
+		if key == "" || strings.ContainsAny(key, ":*") {
+			panic("invalid param name in path: " + pattern)
+		}
+		keys = append(keys, key)
+		b.WriteString(seg)
+	}
+	return b.String(), keys, list
+}
+
+// constraint returns the constraint for a path param, or nil if the param doesn't use one.
+func (r *Route) constraint(key string) *regexp.Regexp {
+	for _, pc := range r.constraints {
+		if pc.key == key {
+			return pc.re
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// UUID is a 128 bit universally unique identifier, as defined in RFC 4122.
+type UUID [16]byte
+
+// ParseUUID parses a UUID in the canonical form, like "123e4567-e89b-12d3-a456-426614174000".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errors.New("invalid uuid")
+	}
+	src := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(src)); err != nil {
+		return u, errors.New("invalid uuid")
+	}
+	return u, nil
+}
+
+// String returns the UUID in the canonical form.
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (u *UUID) UnmarshalText(b []byte) error {
+	id, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = id
+	return nil
+}
+
+// invalidParam returns a '400 bad request' error for the path param.
+func (c *Context) invalidParam(key string) error {
+	return c.Error(http.StatusBadRequest, "invalid param: "+key)
+}
+
+// ParamInt returns a path param as an int, or a '400 bad request' *Error if it's not a valid int.
+func (c *Context) ParamInt(key string) (int, error) {
+	i, err := strconv.Atoi(c.GetParams(key))
+	if err != nil {
+		return 0, c.invalidParam(key)
+	}
+	return i, nil
+}
+
+// ParamUUID returns a path param as an UUID, or a '400 bad request' *Error if it's not a valid UUID.
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	u, err := ParseUUID(c.GetParams(key))
+	if err != nil {
+		return u, c.invalidParam(key)
+	}
+	return u, nil
+}
+
+// ParamTime returns a path param as a time.Time, parsed using layout (see time.Parse() for more info), or a
+// '400 bad request' *Error if it's not a valid time.
+func (c *Context) ParamTime(key, layout string) (time.Time, error) {
+	t, err := time.Parse(layout, c.GetParams(key))
+	if err != nil {
+		return t, c.invalidParam(key)
+	}
+	return t, nil
+}
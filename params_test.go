@@ -0,0 +1,148 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestParamConstraints(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	echo := func(c *Context) error {
+		return c.String(200, fmt.Sprint(c.P))
+	}
+	m.Register("GET", "/int/:id<int>", echo)
+	m.Register("GET", "/uuid/:id<uuid>", echo)
+	m.Register("GET", "/slug/:slug<slug>/edit", echo)
+	m.Register("GET", "/regexp/:year<[0-9]{4}>/:month<int>", echo).Named("archive")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/int/10", 200},
+		{"/int/-10", 200},
+		{"/int/1a", 404},
+		{"/uuid/123e4567-e89b-12d3-a456-426614174000", 200},
+		{"/uuid/123e4567", 404},
+		{"/slug/hello-world/edit", 200},
+		{"/slug/Hello_World/edit", 404},
+		{"/regexp/2021/1", 200},
+		{"/regexp/21/1", 404},
+		{"/regexp/20210/1", 404},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+	}
+
+	url, err := m.URL("archive", 2021, 1)
+	if err != nil || url != "/regexp/2021/1" {
+		t.Errorf("got URL %q and error %q, wanted %q", url, err, "/regexp/2021/1")
+	}
+	if _, err := m.URL("archive", 21, 1); err == nil {
+		t.Errorf("expected error for URL with invalid param")
+	}
+
+	t.Run("invalid constraint", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for catchall constraint")
+			}
+		}()
+		m.Register("GET", "/files/*path<int>", echo)
+	})
+}
+
+func TestParamConstraintsOverlap(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	handler := func(name string) Handler {
+		return func(c *Context) error {
+			return c.String(200, name+fmt.Sprint(c.P))
+		}
+	}
+	m.Register("GET", "/users/:id<int>", handler("int"))
+	m.Register("GET", "/users/:slug<slug>", handler("slug"))
+	m.Register("GET", "/users/*path", handler("catchall"))
+	m.Register("GET", "/posts/:id<int>/edit", handler("edit"))
+	m.Register("GET", "/posts/:name/comments", handler("comments"))
+	m.Register("POST", "/items/:id<int>", handler("item"))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/users/10", 200, "int[{id 10}]"},
+		{"/users/hello-world", 200, "slug[{slug hello-world}]"},
+		{"/users/Hello_World", 200, "catchall[{path /Hello_World}]"},
+		{"/posts/10/edit", 200, "edit[{id 10}]"},
+		{"/posts/10/comments", 200, "comments[{name 10}]"},
+		{"/posts/x/edit", 404, "404 page not found\n"},
+		{"/items/abc", 404, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+	}
+
+	resp := assert.DoRequest(t, m, "GET", "/items/10", nil, nil)
+	assert.StatusCode(t, resp, http.StatusMethodNotAllowed)
+	assert.Header(t, resp, "Allow", "OPTIONS, POST")
+
+	t.Run("duplicate constraint", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for duplicate route")
+			}
+		}()
+		m.Register("GET", "/users/:name<int>", handler("dup"))
+	})
+}
+
+func TestTypedParams(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	m.Register("GET", "/int/:v", func(c *Context) error {
+		i, err := c.ParamInt("v")
+		if err != nil {
+			return err
+		}
+		return c.String(200, fmt.Sprint(i))
+	})
+	m.Register("GET", "/uuid/:v", func(c *Context) error {
+		u, err := c.ParamUUID("v")
+		if err != nil {
+			return err
+		}
+		return c.String(200, u.String())
+	})
+	m.Register("GET", "/time/:v", func(c *Context) error {
+		tm, err := c.ParamTime("v", "2006-01-02")
+		if err != nil {
+			return err
+		}
+		return c.String(200, tm.Format(time.RFC3339))
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/int/42", 200, "42"},
+		{"/int/x", 400, "invalid param: v\n"},
+		{"/uuid/123E4567-E89B-12D3-A456-426614174000", 200, "123e4567-e89b-12d3-a456-426614174000"},
+		{"/uuid/123e4567-e89b-12d3-a456-42661417400x", 400, "invalid param: v\n"},
+		{"/time/2021-02-03", 200, "2021-02-03T00:00:00Z"},
+		{"/time/2021-02-30", 400, "invalid param: v\n"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+	}
+}
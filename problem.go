@@ -0,0 +1,204 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Problem is an error with details about a failed request, which can be sent to clients as a RFC 7807
+// "application/problem+json" response (see ProblemErrorHandler()). For more info, see:
+// https://tools.ietf.org/html/rfc7807
+//
+// A Problem can wrap another error as it's cause, which is never sent to the client. The cause is available using
+// errors.Unwrap() (or errors.Is() and errors.As()), while errors.Cause() stops at the Problem so that error handlers
+// can still find it, even if the Problem itself was wrapped with errors.Wrap().
+type Problem struct {
+	// Type is an URI reference that identifies the problem type. Defaults to "about:blank".
+	Type string
+	// Title is a short summary of the problem type. Defaults to the status text of the http status code.
+	Title string
+	// Status is the http status code. Defaults to 500 (internal server error) if it's unset or invalid.
+	Status int
+	// Detail is an explanation specific to this problem, which is safe to show to the client.
+	Detail string
+	// Instance is an URI reference that identifies this occurrence of the problem. Defaults to the request's path.
+	Instance string
+	// Code is an optional, application specific error code.
+	Code string
+	// Extensions contains any extra members for the problem details, which are also safe to show to the client.
+	Extensions map[string]interface{}
+
+	cause error
+}
+
+// NewProblem returns a new Problem with a http status code and a public detail message.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WrapProblem returns a new Problem with a http status code and a public detail message, which wraps the cause. The
+// cause will be logged by the error handlers but never sent to the client.
+func WrapProblem(cause error, status int, detail string) *Problem {
+	return &Problem{
+		Status: status,
+		Detail: detail,
+		cause:  cause,
+	}
+}
+
+// WithCode sets an application specific error code for the problem.
+func (p *Problem) WithCode(code string) *Problem {
+	p.Code = code
+	return p
+}
+
+// With sets an extension member for the problem details.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	msg := p.title()
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.cause != nil {
+		msg += ": " + p.cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the cause of the problem, or nil if there's none.
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+func (p *Problem) title() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.status())
+}
+
+// status returns the http status code, or 500 if it's unset or invalid (which would make http.ResponseWriter panic).
+func (p *Problem) status() int {
+	if p.Status < 100 || p.Status > 999 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// MarshalJSON implements the json.Marshaler interface, with any extensions as top level members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	if p.Type == "" {
+		m["type"] = "about:blank"
+	}
+	m["title"] = p.title()
+	m["status"] = p.status()
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if p.Code != "" {
+		m["code"] = p.Code
+	}
+	return json.Marshal(m)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var problemTemplate = template.Must(template.New("problem").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{- if .Detail}}
+<p>{{.Detail}}</p>
+{{- end}}
+{{- if .Code}}
+<p>Error code: {{.Code}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// toProblem converts an error to a Problem, using the first Problem found in the error chain or by converting
+// *Error and FieldErrors. All other errors will be a "500 internal server error" Problem, without any details.
+func toProblem(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	switch e := errors.Cause(err).(type) {
+	case *Error:
+		return NewProblem(e.Status(), e.Error())
+	case FieldErrors:
+		return NewProblem(http.StatusUnprocessableEntity, "").With("errors", e.FieldErrors())
+	default:
+		return NewProblem(http.StatusInternalServerError, "")
+	}
+}
+
+// ProblemErrorHandler is an alternative to SimpleErrorHandler, which sends all errors as RFC 7807 problem details
+// (see Problem). The response format is chosen using the request's "Accept" header, between
+// "application/problem+json" (which is the default, also sent for "application/json"), HTML or plain text.
+// *Error and FieldErrors are converted to problems and all other errors are sent as "500 internal server error"
+// problems, without any details. Server errors (with 5xx status codes) are returned for logging.
+func ProblemErrorHandler(c *Context, err error) error {
+	p := *toProblem(err)
+	p.Status = p.status()
+	p.Title = p.title()
+	if p.Instance == "" {
+		p.Instance = c.R.URL.Path
+	}
+
+	var ret error
+	if p.Status >= 500 {
+		ret = err
+	}
+	h := c.W.Header()
+	h.Add("Vary", "Accept")
+	h.Set("X-Content-Type-Options", "nosniff")
+	mt, _ := negotiate(c.GetHeader("Accept"), []string{"application/problem+json", "application/json", "text/html",
+		"text/plain"})
+	switch mt {
+	case "text/html":
+		buff := c.M.getTemplateBuff()
+		defer c.M.putTemplateBuff(buff)
+		if err := problemTemplate.Execute(buff, p); err != nil {
+			return err
+		}
+		_ = c.HTML(p.Status, buff.String()) // Don't care about write errors
+	case "text/plain":
+		msg := p.Title
+		if p.Detail != "" {
+			msg += ": " + p.Detail
+		}
+		_ = c.String(p.Status, msg+"\n")
+	default:
+		b, err := json.Marshal(&p)
+		if err != nil {
+			return err
+		}
+		h.Set("Content-Type", "application/problem+json")
+		_ = c.Bytes(p.Status, append(b, '\n'))
+	}
+	return ret
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+	"github.com/pkg/errors"
+)
+
+type testFieldErrors map[string][]string
+
+func (e testFieldErrors) Error() string                    { return "invalid fields" }
+func (e testFieldErrors) FieldErrors() map[string][]string { return e }
+
+func TestProblem(t *testing.T) {
+	cause := errors.New("database is down")
+	p := WrapProblem(cause, http.StatusServiceUnavailable, "try again later").WithCode("db_down")
+	wrapped := errors.Wrap(p, "loading user")
+
+	if errors.Cause(wrapped) != p {
+		t.Errorf("got cause %q, wanted the problem", errors.Cause(wrapped))
+	}
+	if !errors.Is(wrapped, cause) {
+		t.Errorf("expected the problem to unwrap to it's cause")
+	}
+	want := "loading user: Service Unavailable: try again later: database is down"
+	if wrapped.Error() != want {
+		t.Errorf("got error %q, wanted %q", wrapped.Error(), want)
+	}
+
+	b, err := json.Marshal(NewProblem(http.StatusNotFound, "no such user").With("id", 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"detail":"no such user","id":10,"status":404,"title":"Not Found","type":"about:blank"}`
+	if string(b) != want {
+		t.Errorf("got json %s, wanted %s", b, want)
+	}
+	b, err = json.Marshal(&Problem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"status":500,"title":"Internal Server Error","type":"about:blank"}`
+	if string(b) != want {
+		t.Errorf("got json %s, wanted %s", b, want)
+	}
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	var logged []error
+	m := NewMux(&MuxOptions{
+		HandleError: func(c *Context, err error) error {
+			if e := ProblemErrorHandler(c, err); e != nil {
+				logged = append(logged, e)
+			}
+			return nil
+		},
+	})
+	m.Register("GET", "/problem", func(c *Context) error {
+		return errors.Wrap(WrapProblem(errors.New("secret"), http.StatusConflict, "already exists").
+			WithCode("dup"), "creating user")
+	})
+	m.Register("GET", "/error", func(c *Context) error {
+		return c.Error(http.StatusBadRequest, "bad input")
+	})
+	m.Register("GET", "/fields", func(c *Context) error {
+		return testFieldErrors{"name": {"is required"}}
+	})
+	m.Register("GET", "/internal", func(c *Context) error {
+		return errors.New("secret")
+	})
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"/problem", "", http.StatusConflict, "application/problem+json", `{"code":"dup","detail":"already exists",` +
+			`"instance":"/problem","status":409,"title":"Conflict","type":"about:blank"}` + "\n"},
+		{"/problem", "text/plain", http.StatusConflict, "text/plain; charset=utf-8", "Conflict: already exists\n"},
+		{"/error", "application/json", http.StatusBadRequest, "application/problem+json", `{"detail":"bad input",` +
+			`"instance":"/error","status":400,"title":"Bad Request","type":"about:blank"}` + "\n"},
+		{"/fields", "", http.StatusUnprocessableEntity, "application/problem+json", `{"errors":{"name":` +
+			`["is required"]},"instance":"/fields","status":422,"title":"Unprocessable Entity","type":"about:blank"}` +
+			"\n"},
+		{"/internal", "text/plain", http.StatusInternalServerError, "text/plain; charset=utf-8",
+			"Internal Server Error\n"},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Accept", tt.accept)
+		resp := assert.DoRequest(t, m, "GET", tt.path, h, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Header(t, resp, "Content-Type", tt.contentType)
+		assert.Body(t, resp, tt.body)
+	}
+	if len(logged) != 1 || logged[0].Error() != "secret" {
+		t.Errorf("got logged errors %v, wanted only the internal error", logged)
+	}
+
+	t.Run("html", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		resp := assert.DoRequest(t, m, "GET", "/problem", h, nil)
+		assert.StatusCode(t, resp, http.StatusConflict)
+		assert.Header(t, resp, "Content-Type", "text/html; charset=utf-8")
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "<p>already exists</p>") || strings.Contains(string(b), "secret") {
+			t.Errorf("got unexpected html body: %s", b)
+		}
+	})
+
+	t.Run("simple error handler", func(t *testing.T) {
+		m := testMux(t, "GET", "/", func(c *Context) error {
+			return errors.Wrap(NewProblem(http.StatusConflict, "already exists"), "creating user")
+		})
+		resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+		assert.StatusCode(t, resp, http.StatusConflict)
+		assert.Body(t, resp, "already exists\n")
+
+		m.Register("GET", "/unset", func(c *Context) error {
+			return &Problem{Detail: "oops"}
+		})
+		resp = assert.DoRequest(t, m, "GET", "/unset", nil, nil)
+		assert.StatusCode(t, resp, http.StatusInternalServerError)
+		assert.Body(t, resp, "oops\n")
+	})
+
+	t.Run("unset status", func(t *testing.T) {
+		m := NewMux(&MuxOptions{HandleError: ProblemErrorHandler})
+		m.Register("GET", "/", func(c *Context) error {
+			return &Problem{Detail: "oops"}
+		})
+		h := http.Header{}
+		h.Set("Accept", "text/plain")
+		resp := assert.DoRequest(t, m, "GET", "/", h, nil)
+		assert.StatusCode(t, resp, http.StatusInternalServerError)
+		assert.Body(t, resp, "Internal Server Error: oops\n")
+	})
+}
@@ -0,0 +1,234 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EncoderFunc is a function signature used for encoding the data for a response, with a http status.
+// The template name is only used by HTML encoders, see Context.Respond().
+type EncoderFunc func(c *Context, status int, data interface{}, tmpl string) error
+
+// Encoder is used by Context.Respond() to encode the data for a response, when the media type has been accepted by
+// the client.
+type Encoder struct {
+	// MediaType is the type of the encoded data, like "application/json"
+	MediaType string
+	// Template makes the encoder available only when a template name has been given to Context.Respond()
+	Template bool
+	// Encode encodes the data and sends the response
+	Encode EncoderFunc
+}
+
+// DefaultEncoders returns the default list of encoders, used when MuxOptions.Encoders is not set. The encoders are,
+// in order of preference: HTML templates (using Context.Render()), JSON, XML, plain text and CSV.
+func DefaultEncoders() []Encoder {
+	return []Encoder{
+		{"text/html", true, encodeHTML},
+		{"application/json", false, encodeJSON},
+		{"application/xml", false, encodeXML},
+		{"text/plain", false, encodeText},
+		{"text/csv", false, encodeCSV},
+	}
+}
+
+// Respond sends the data in a format chosen by the request's "Accept" header (including any quality values), using
+// the encoders set in MuxOptions.Encoders. Encoders with a higher quality value are preferred, with any ties sorted
+// by the order of the encoders. HTML templates are only used when tmpl is a non empty template name.
+// If the client doesn't accept any of the encoders' media types, it returns a '406 not acceptable' *Error.
+func (c *Context) Respond(status int, data interface{}, tmpl string) error {
+	var encoders []Encoder
+	var offers []string
+	for _, e := range c.M.opt.Encoders {
+		if !e.Template || tmpl != "" {
+			encoders = append(encoders, e)
+			offers = append(offers, e.MediaType)
+		}
+	}
+	c.W.Header().Add("Vary", "Accept")
+	mt, ok := negotiate(c.GetHeader("Accept"), offers)
+	if !ok {
+		return c.Error(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+	}
+	// Only use the offered encoders, as a template encoder might use the same media type as another encoder
+	for _, e := range encoders {
+		if e.MediaType == mt {
+			return e.Encode(c, status, data, tmpl)
+		}
+	}
+	return nil // Not reached
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// acceptRange is a single media range from an "Accept" header.
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an "Accept" header into a list of media ranges. Invalid ranges are ignored.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		slash := strings.IndexByte(mt, '/')
+		if slash < 1 || slash == len(mt)-1 {
+			continue
+		}
+		r := acceptRange{typ: mt[:slash], subtype: mt[slash+1:], q: 1}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && (p[0] == 'q' || p[0] == 'Q') && p[1] == '=' {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// quality returns the quality value of the most specific range matching the media type, or -1 if none matched.
+func quality(ranges []acceptRange, mediaType string) float64 {
+	slash := strings.IndexByte(mediaType, '/')
+	typ, subtype := mediaType[:slash], mediaType[slash+1:]
+	q, best := -1.0, -1
+	for _, r := range ranges {
+		specificity := 0
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			specificity = 2
+		case r.typ == typ && r.subtype == "*":
+			specificity = 1
+		case r.typ == "*" && r.subtype == "*":
+			specificity = 0
+		default:
+			continue
+		}
+		if specificity > best {
+			q, best = r.q, specificity
+		}
+	}
+	return q
+}
+
+// negotiate returns the offered media type with the highest quality value in the "Accept" header, with ties sorted
+// by the order of the offers. If the header is empty, the first offer is returned.
+func negotiate(header string, offers []string) (string, bool) {
+	if len(offers) < 1 {
+		return "", false
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+	ranges := parseAccept(header)
+	var best string
+	bestQ := 0.0
+	for _, o := range offers {
+		if q := quality(ranges, strings.ToLower(o)); q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func encodeHTML(c *Context, status int, data interface{}, tmpl string) error {
+	return c.Render(status, tmpl, data)
+}
+
+func encodeJSON(c *Context, status int, data interface{}, tmpl string) error {
+	return c.JSON(status, data)
+}
+
+func encodeXML(c *Context, status int, data interface{}, tmpl string) error {
+	b, err := xml.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "encoding xml")
+	}
+	c.SetHeader("Content-Type", "application/xml; charset=utf-8")
+	c.SetHeader("X-Content-Type-Options", "nosniff")
+	return c.Bytes(status, append([]byte(xml.Header), b...))
+}
+
+func encodeText(c *Context, status int, data interface{}, tmpl string) error {
+	return c.String(status, fmt.Sprint(data))
+}
+
+// encodeCSV encodes data of type [][]string, or a slice of structs (with a header row using the field names or their
+// "csv" tags).
+func encodeCSV(c *Context, status int, data interface{}, tmpl string) error {
+	records, ok := data.([][]string)
+	if !ok {
+		var err error
+		records, err = structRecords(data)
+		if err != nil {
+			return err
+		}
+	}
+	c.SetHeader("Content-Type", "text/csv; charset=utf-8")
+	c.SetHeader("X-Content-Type-Options", "nosniff")
+	c.W.WriteHeader(status)
+	w := csv.NewWriter(c.W)
+	return w.WriteAll(records)
+}
+
+func structRecords(data interface{}) ([][]string, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return nil, errors.Errorf("encoding csv: unsupported type %T", data)
+	}
+	t := v.Type().Elem()
+	ptr := t.Kind() == reflect.Ptr
+	if ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("encoding csv: unsupported type %T", data)
+	}
+
+	var fields []int
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("csv")
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	records := [][]string{header}
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		if ptr {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+		rec := make([]string, len(fields))
+		for j, f := range fields {
+			rec[j] = fmt.Sprint(row.Field(f).Interface())
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"text/html", "application/json", "text/plain"}
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "text/html", true},
+		{"*/*", "text/html", true},
+		{"application/json", "application/json", true},
+		{"application/JSON; charset=utf-8", "application/json", true},
+		{"text/*", "text/html", true},
+		{"text/html;q=0.5, text/plain", "text/plain", true},
+		{"text/*;q=0.5, application/json;q=0.8", "application/json", true},
+		{"text/html;q=0, */*;q=0.1", "application/json", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html", true},
+		{"image/png", "", false},
+		{"*/*;q=0", "", false},
+		{"invalid, /, text/", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := negotiate(tt.accept, offers)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("got %q %v for %q, wanted %q %v", got, ok, tt.accept, tt.want, tt.ok)
+		}
+	}
+}
+
+type respondItem struct {
+	Name  string `csv:"name" json:"name" xml:"name"`
+	Count int    `csv:"count" json:"count" xml:"count"`
+}
+
+func (i respondItem) String() string {
+	return i.Name
+}
+
+func TestRespond(t *testing.T) {
+	m := NewMux(&MuxOptions{
+		Templates: map[string]*template.Template{
+			"item.html": template.Must(template.New("item.html").Parse("<b>{{.Name}}</b>")),
+		},
+	})
+	item := respondItem{"a", 1}
+	m.Register("GET", "/item", func(c *Context) error {
+		return c.Respond(200, item, "item.html")
+	})
+	m.Register("GET", "/items", func(c *Context) error {
+		return c.Respond(200, []respondItem{item, {"b", 2}}, "")
+	})
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"/item", "", 200, "text/html; charset=utf-8", "<b>a</b>"},
+		{"/item", "application/json", 200, "application/json; charset=utf-8", `{"name":"a","count":1}` + "\n"},
+		{"/item", "application/xml", 200, "application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n<respondItem><name>a</name><count>1</count></respondItem>"},
+		{"/item", "text/plain", 200, "text/plain; charset=utf-8", "a"},
+		{"/item", "image/png", http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+		{"/items", "", 200, "application/json; charset=utf-8",
+			`[{"name":"a","count":1},{"name":"b","count":2}]` + "\n"},
+		{"/items", "text/html", http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+		{"/items", "text/csv", 200, "text/csv; charset=utf-8", "name,count\na,1\nb,2\n"},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Accept", tt.accept)
+		resp := assert.DoRequest(t, m, "GET", tt.path, h, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Header(t, resp, "Content-Type", tt.contentType)
+		assert.Header(t, resp, "Vary", "Accept")
+		assert.Body(t, resp, tt.body)
+	}
+
+	t.Run("custom encoders", func(t *testing.T) {
+		m := NewMux(&MuxOptions{
+			Encoders: []Encoder{
+				{"application/vnd.test", false, func(c *Context, status int, data interface{}, tmpl string) error {
+					return c.String(status, "custom")
+				}},
+			},
+		})
+		m.Register("GET", "/", func(c *Context) error {
+			return c.Respond(200, nil, "")
+		})
+		resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+		assert.StatusCode(t, resp, 200)
+		assert.Body(t, resp, "custom")
+
+		h := http.Header{}
+		h.Set("Accept", "application/json")
+		resp = assert.DoRequest(t, m, "GET", "/", h, nil)
+		assert.StatusCode(t, resp, http.StatusNotAcceptable)
+	})
+	t.Run("shared media type", func(t *testing.T) {
+		m := NewMux(&MuxOptions{
+			Encoders: []Encoder{
+				{"text/html", true, encodeHTML},
+				{"text/html", false, func(c *Context, status int, data interface{}, tmpl string) error {
+					return c.HTML(status, "<p>no template</p>")
+				}},
+			},
+		})
+		m.Register("GET", "/", func(c *Context) error {
+			return c.Respond(200, nil, "")
+		})
+		resp := assert.DoRequest(t, m, "GET", "/", nil, nil)
+		assert.StatusCode(t, resp, 200)
+		assert.Body(t, resp, "<p>no template</p>")
+	})
+}
@@ -0,0 +1,309 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RouteOptions contains optional settings for a single route.
+type RouteOptions struct {
+	// Timeout sets a deadline for the request's context, which can be checked with Context.Deadline() or
+	// Context.R.Context().Done().
+	Timeout time.Duration
+	// MaxBodySize limits the size of a request's body (in bytes). Requests with a larger body will get a
+	// "413 request entity too large" response, or an error when reading the body.
+	MaxBodySize int64
+	// ContentTypes is a list of media types allowed for a request's body, like "application/json" or "text/*".
+	// Requests with other Content-Types will get a "415 unsupported media type" response.
+	ContentTypes []string
+	// Meta is a map of free form tags, that can be read by middlewares using Context.Route.Meta(). For example
+	// middlewares.AccessLog() will skip logging requests if a route has the "accesslog" tag set to "off".
+	Meta map[string]string
+}
+
+// Route is a handler registered for a http method and URL on a Mux.
+type Route struct {
+	mux         *Mux
+	method      string
+	path        string
+	pattern     string
+	keys        []string
+	constraints []paramConstraint
+	handler     Handler
+	opt         *RouteOptions
+	name        string
+	middlewares []string
+	source      string
+}
+
+func newRoute(m *Mux, method, path string, opt *RouteOptions, mw []Middleware) *Route {
+	if opt == nil {
+		opt = &RouteOptions{}
+	}
+	o := *opt // Copied, so the options can't be modified while serving requests
+	r := &Route{
+		mux:    m,
+		method: method,
+		path:   path,
+		opt:    &o,
+		source: callerSource(),
+	}
+	r.pattern, r.keys, r.constraints = parsePattern(path)
+	for _, f := range mw {
+		r.middlewares = append(r.middlewares, funcName(f))
+	}
+	return r
+}
+
+// Named sets a name for the route, so it's URL can later be rebuilt using Mux.URL() (or Context.URL(), or the
+// "urlfor" template func).
+// NOTE: it will cause a panic if the name is already used by another route. The name should not be changed after the
+// route has started serving requests.
+func (r *Route) Named(name string) *Route {
+	if name == "" {
+		panic("route name can't be empty")
+	}
+	r.mux.updateTable(func(t *routeTable) *routeTable {
+		if _, found := t.names[name]; found {
+			panic("route name already registered: " + name)
+		}
+		delete(t.names, r.name)
+		r.name = name
+		t.names[name] = r
+		return t
+	})
+	return r
+}
+
+// Options returns the optional settings for the route (see Mux.RegisterWithOptions()).
+// NOTE: the options should not be changed.
+func (r *Route) Options() *RouteOptions {
+	return r.opt
+}
+
+// Meta returns the value of a free form tag in the route's options (see RouteOptions). It's safe to call on a nil
+// Route, which always returns an empty string.
+func (r *Route) Meta(key string) string {
+	if r == nil {
+		return ""
+	}
+	return r.opt.Meta[key]
+}
+
+// withOptions is a middleware that applies the route's options to a request, before calling the next Handler. It
+// runs after the global middlewares but wraps the route's middlewares, so they will also be affected by the
+// options. Any rejected requests are passed directly to the Mux's error handler, as errors returned to the global
+// middlewares won't be handled.
+func (r *Route) withOptions(next Handler) Handler {
+	return func(c *Context) error {
+		opt := r.opt
+		if opt.MaxBodySize > 0 && c.R.ContentLength > opt.MaxBodySize {
+			return r.reject(c, http.StatusRequestEntityTooLarge)
+		}
+		if len(opt.ContentTypes) > 0 && c.R.ContentLength != 0 && !matchMediaType(opt.ContentTypes,
+			c.GetHeader("Content-Type")) {
+			return r.reject(c, http.StatusUnsupportedMediaType)
+		}
+
+		req := c.R
+		if opt.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), opt.Timeout)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+		if opt.MaxBodySize > 0 {
+			if req == c.R {
+				req = req.WithContext(req.Context())
+			}
+			req.Body = http.MaxBytesReader(c.W, req.Body, opt.MaxBodySize)
+		}
+		c.R = req
+		return next(c)
+	}
+}
+
+func (r *Route) reject(c *Context, status int) error {
+	return r.mux.opt.HandleError(c, c.Error(status, http.StatusText(status)))
+}
+
+// matchMediaType returns true if the media type of a Content-Type header matches any of the allowed types.
+func matchMediaType(allowed []string, contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mt || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, a[:len(a)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the name of the route, or an empty string if it hasn't been named.
+func (r *Route) Name() string {
+	return r.name
+}
+
+// Method returns the http method for the route.
+func (r *Route) Method() string {
+	return r.method
+}
+
+// Path returns the URL path pattern for the route, including any param constraints.
+func (r *Route) Path() string {
+	return r.path
+}
+
+// Host returns the host name the route was registered for, or an empty string for the default host.
+// See Mux.Host() for more info.
+func (r *Route) Host() string {
+	return r.mux.host
+}
+
+// Middlewares returns the names of all the middlewares used by the route, in the order they will be executed.
+func (r *Route) Middlewares() []string {
+	return append([]string(nil), r.middlewares...)
+}
+
+// Source returns the file name and line number where the route was registered.
+func (r *Route) Source() string {
+	return r.source
+}
+
+// URL builds a new URL path for the route, by replacing the ":param" and "*catchall" parts of the route's path with
+// the values in params, in the same order as they appear in the path.
+// Values are escaped and a catchall value may contain multiple path segments (separated by slashes).
+// Any values that doesn't match the param constraints will return an error.
+func (r *Route) URL(params ...interface{}) (string, error) {
+	var b strings.Builder
+	p, i := r.pattern, 0
+	for len(p) > 0 {
+		start := strings.IndexAny(p, ":*")
+		if start < 0 {
+			b.WriteString(p)
+			break
+		}
+		b.WriteString(p[:start])
+		kind := p[start]
+		p = p[start+1:]
+		end := strings.IndexByte(p, '/')
+		if end < 0 {
+			end = len(p)
+		}
+		key := p[:end]
+		p = p[end:]
+
+		if i >= len(params) {
+			return "", errors.Errorf("missing value for param %q in route %q", key, r.path)
+		}
+		val := fmt.Sprint(params[i])
+		i++
+		if kind == ':' {
+			if val == "" {
+				return "", errors.Errorf("empty value for param %q in route %q", key, r.path)
+			}
+			for _, pc := range r.constraints {
+				if pc.key == key && !pc.re.MatchString(val) {
+					return "", errors.Errorf("invalid value for param %q in route %q", key, r.path)
+				}
+			}
+			b.WriteString(url.PathEscape(val))
+			continue
+		}
+
+		// The catchall is always preceded by a slash in the path already
+		parts := strings.Split(strings.TrimPrefix(val, "/"), "/")
+		for j, part := range parts {
+			if j > 0 {
+				b.WriteByte('/')
+			}
+			b.WriteString(url.PathEscape(part))
+		}
+	}
+	if i < len(params) {
+		return "", errors.Errorf("too many param values for route %q", r.path)
+	}
+	return b.String(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// URL builds a new URL path for a route registered with a name, using params as values for the route's path params.
+// See Route.URL() for more info.
+func (m *Mux) URL(name string, params ...interface{}) (string, error) {
+	r, found := m.loadTable().names[name]
+	if !found {
+		return "", errors.Errorf("unknown route name: %s", name)
+	}
+	return r.URL(params...)
+}
+
+// Routes returns a list of all routes registered on the Mux, in the same order as they were registered. Routes for
+// the other hosts (see Mux.Host()) are included last, sorted by the host names.
+func (m *Mux) Routes() []*Route {
+	list := append([]*Route(nil), m.loadTable().routes...)
+	ht := m.loadHosts()
+	if ht == nil {
+		return list
+	}
+	hosts := make([]string, 0, len(ht.hosts))
+	for h := range ht.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		list = append(list, ht.hosts[h].Routes()...)
+	}
+	return list
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var pkgPath = reflect.TypeOf((*Mux)(nil)).Elem().PkgPath()
+
+// callerSource returns the file name and line number for the first caller outside of the route registering methods.
+func callerSource() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPath+".(*Mux).") &&
+			!strings.HasPrefix(f.Function, pkgPath+".(*Group).") {
+			return f.File + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// Matches the suffixes for anonymous funcs, like in "web.TestMiddleware.func1.1"
+var anonFuncSuffix = regexp.MustCompile(`(\.func\d+|\.\d+)+$`)
+
+// funcName returns the short name of a func, like "middlewares.AccessLog".
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return anonFuncSuffix.ReplaceAllString(name, "")
+}
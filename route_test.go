@@ -0,0 +1,269 @@
+package web
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestRouteURL(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	h := func(c *Context) error { return nil }
+	m.Register("GET", "/users", h).Named("users")
+	m.Register("GET", "/users/:id/edit", h).Named("user.edit")
+	m.Register("GET", "/files/:dir/*filepath", h).Named("files")
+	m.Group("/api").Register("GET", "/items/:id", h).Named("api.item")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   string
+		err    bool
+	}{
+		{"users", nil, "/users", false},
+		{"user.edit", []interface{}{10}, "/users/10/edit", false},
+		{"user.edit", []interface{}{"a b/c"}, "/users/a%20b%2Fc/edit", false},
+		{"files", []interface{}{"docs", "/a b/c.txt"}, "/files/docs/a%20b/c.txt", false},
+		{"files", []interface{}{"docs", "c.txt"}, "/files/docs/c.txt", false},
+		{"api.item", []interface{}{"x"}, "/api/items/x", false},
+		{"user.edit", nil, "", true},
+		{"user.edit", []interface{}{""}, "", true},
+		{"user.edit", []interface{}{1, 2}, "", true},
+		{"missing", nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := m.URL(tt.name, tt.params...)
+		if (err != nil) != tt.err {
+			t.Errorf("got error %q for route %q", err, tt.name)
+		}
+		if got != tt.want {
+			t.Errorf("got URL %q for route %q, wanted %q", got, tt.name, tt.want)
+		}
+	}
+
+	t.Run("duplicate names", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for duplicate route name")
+			}
+		}()
+		m.Register("GET", "/other", h).Named("users")
+	})
+}
+
+func TestTemplateURLFor(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.html")
+	if err := ioutil.WriteFile(file, []byte(`{{urlfor "user" .}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMux(&MuxOptions{
+		Templates: LoadTemplates(filepath.Join(dir, "*.html"), nil),
+	})
+	m.Register("GET", "/users/:id", func(c *Context) error {
+		return c.Render(200, "test.html", c.GetParams("id"))
+	}).Named("user")
+
+	resp := assert.DoRequest(t, m, "GET", "/users/a&b", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Body(t, resp, "/users/a&amp;b")
+}
+
+func TestLoadTemplatesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/a_layout.html": {Data: []byte(`<main>{{template "content" .}}</main>`)},
+		"templates/page.html":     {Data: []byte(`{{define "content"}}{{urlfor "page"}} {{.}}{{end}}`)},
+		"templates/plain.html":    {Data: []byte(`plain {{.}}`)},
+		"other.txt":               {Data: []byte(`ignored`)},
+	}
+	plain := LoadTemplatesFS(fsys, "templates/*.html", nil)
+	if len(plain) != 3 || plain["plain.html"] == nil {
+		t.Errorf("got templates %v, wanted 3 templates", plain)
+	}
+	m := NewMux(&MuxOptions{
+		Templates: LoadTemplatesWithLayoutFS(fsys, "templates/*.html", nil),
+	})
+	m.Register("GET", "/page", func(c *Context) error {
+		return c.Render(200, "page.html", "hello")
+	}).Named("page")
+
+	resp := assert.DoRequest(t, m, "GET", "/page", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Body(t, resp, "<main>/page hello</main>")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for missing templates")
+		}
+	}()
+	LoadTemplatesWithLayoutFS(fsys, "missing/*.html", nil)
+}
+
+func TestRoutes(t *testing.T) {
+	mw := func(next Handler) Handler {
+		return next
+	}
+	m := NewMux(&MuxOptions{
+		Middlewares: []Middleware{mw},
+	})
+	h := func(c *Context) error { return nil }
+	m.Register("GET", "/hello", h).Named("hello")
+	m.Group("/api", mw).Register("POST", "/items", h, mw)
+	m.Host("example.org", nil).Register("GET", "/", h)
+
+	routes := m.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("got %d routes, wanted 3", len(routes))
+	}
+	tests := []struct {
+		host, method, path, name string
+		middlewares              int
+	}{
+		{"", "GET", "/hello", "hello", 1},
+		{"", "POST", "/api/items", "", 3},
+		{"example.org", "GET", "/", "", 0},
+	}
+	for i, tt := range tests {
+		r := routes[i]
+		if r.Host() != tt.host || r.Method() != tt.method || r.Path() != tt.path || r.Name() != tt.name {
+			t.Errorf("got route %q %q %q %q, wanted %q %q %q %q", r.Host(), r.Method(), r.Path(), r.Name(),
+				tt.host, tt.method, tt.path, tt.name)
+		}
+		if len(r.Middlewares()) != tt.middlewares {
+			t.Errorf("got %d middlewares for route %q, wanted %d", len(r.Middlewares()), r.Path(), tt.middlewares)
+		}
+		if !strings.Contains(r.Source(), "route_test.go:") {
+			t.Errorf("got source %q for route %q, wanted route_test.go", r.Source(), r.Path())
+		}
+	}
+	if got := routes[0].Middlewares()[0]; got != "web.TestRoutes" {
+		t.Errorf("got middleware name %q, wanted %q", got, "web.TestRoutes")
+	}
+
+	t.Run("routes handler", func(t *testing.T) {
+		m.Register("GET", "/debug/routes", RoutesHandler)
+		resp := assert.DoRequest(t, m, "GET", "/debug/routes", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Content-Type", "text/html; charset=utf-8")
+
+		resp = assert.DoRequest(t, m, "GET", "/debug/routes?format=json", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Content-Type", "application/json; charset=utf-8")
+		var list []map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 4 || list[0]["path"] != "/hello" {
+			t.Errorf("got unexpected json route list: %v", list)
+		}
+	})
+}
+
+func TestRouteOptions(t *testing.T) {
+	mw := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			c.SetHeader("X-Auth", c.Route.Meta("auth"))
+			return next(c)
+		})
+	}
+	deadline := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			if _, ok := c.Deadline(); ok {
+				c.SetHeader("X-Deadline", "set")
+			}
+			return next(c)
+		})
+	}
+	bodyKey := NewKey[string]("body")
+	readBody := func(next Handler) Handler {
+		return Handler(func(c *Context) error {
+			b, err := ioutil.ReadAll(c.R.Body)
+			if err != nil {
+				return c.String(http.StatusRequestEntityTooLarge, "too large")
+			}
+			SetValue(c, bodyKey, string(b))
+			return next(c)
+		})
+	}
+	m := NewMux(&MuxOptions{
+		Middlewares: []Middleware{mw},
+	})
+	m.RegisterWithOptions("GET", "/timeout", func(c *Context) error {
+		deadline, ok := c.Deadline()
+		if !ok || time.Until(deadline) > time.Minute {
+			return c.String(200, "bad deadline")
+		}
+		return c.String(200, "ok")
+	}, &RouteOptions{
+		Timeout: time.Minute,
+		Meta:    map[string]string{"auth": "required"},
+	}, deadline)
+	m.RegisterWithOptions("POST", "/body", func(c *Context) error {
+		b, err := ioutil.ReadAll(c.R.Body)
+		if err != nil {
+			return c.Error(http.StatusRequestEntityTooLarge, "too large")
+		}
+		return c.String(200, string(b))
+	}, &RouteOptions{
+		MaxBodySize:  5,
+		ContentTypes: []string{"application/json", "text/*"},
+		Meta:         map[string]string{"auth": "none"},
+	})
+
+	m.Group("/group").RegisterWithOptions("POST", "/middleware", func(c *Context) error {
+		body, _ := Value(c, bodyKey)
+		return c.String(200, body)
+	}, &RouteOptions{
+		MaxBodySize: 5,
+	}, readBody)
+
+	resp := assert.DoRequest(t, m, "GET", "/timeout", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Header(t, resp, "X-Auth", "required")
+	assert.Header(t, resp, "X-Deadline", "set")
+	assert.Body(t, resp, "ok")
+
+	tests := []struct {
+		contentType string
+		body        string
+		chunked     bool
+		status      int
+	}{
+		{"text/plain; charset=utf-8", "hello", false, 200},
+		{"Application/JSON", "hello", false, 200},
+		{"application/xml", "hello", false, http.StatusUnsupportedMediaType},
+		{"", "hello", false, http.StatusUnsupportedMediaType},
+		{"text/plain", "hello world", false, http.StatusRequestEntityTooLarge},
+		{"text/plain", "hello world", true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/body", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		m.ServeHTTP(rec, req)
+		resp := rec.Result()
+		assert.StatusCode(t, resp, tt.status)
+		assert.Header(t, resp, "X-Auth", "none") // Rejected requests are still passed through the global middlewares
+	}
+
+	t.Run("middleware reading body", func(t *testing.T) {
+		for body, status := range map[string]int{"hello": 200, "hello world": http.StatusRequestEntityTooLarge} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/group/middleware", strings.NewReader(body))
+			req.ContentLength = -1
+			m.ServeHTTP(rec, req)
+			assert.StatusCode(t, rec.Result(), status)
+		}
+	})
+}
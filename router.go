@@ -0,0 +1,365 @@
+package web
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Param is a single URL param, consisting of a key and a value.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a list of URL params, in the same order as they appear in the route's path.
+type Params []Param
+
+// ByName returns the value of the first param with a matching key. An empty string is returned if no matching param
+// was found.
+func (ps Params) ByName(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// node is a single node in a radix tree of URL paths.
+// Param and catchall nodes doesn't store their keys, instead the keys are stored in the matched Route. This allows
+// routes with different param keys to overlap each other. Params with different constraints are stored as separate
+// nodes, so routes like "/users/:id<int>" and "/users/:name" can overlap too.
+type node struct {
+	prefix     string         // static path prefix for this node
+	indices    []byte         // first byte of the prefix for each static child
+	children   []*node        // static children
+	params     []*node        // param children, matches a single non-empty path segment
+	catchAll   *node          // catchall child, matches the rest of the path
+	route      *Route         // route registered for the path ending at this node
+	constraint *regexp.Regexp // constraint for a param node's value, or nil if any value is allowed
+}
+
+func (n *node) staticChild(b byte) *node {
+	for i, c := range n.indices {
+		if c == b {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// insertStatic inserts a static path under the node, splitting any existing children with common prefixes, and
+// returns the node for the end of the path.
+func (n *node) insertStatic(s string) *node {
+	for len(s) > 0 {
+		c := n.staticChild(s[0])
+		if c == nil {
+			c = &node{prefix: s}
+			n.indices = append(n.indices, s[0])
+			n.children = append(n.children, c)
+			return c
+		}
+
+		l := 0
+		for l < len(s) && l < len(c.prefix) && s[l] == c.prefix[l] {
+			l++
+		}
+		if l < len(c.prefix) {
+			split := *c
+			split.prefix = c.prefix[l:]
+			*c = node{
+				prefix:   c.prefix[:l],
+				indices:  []byte{split.prefix[0]},
+				children: []*node{&split},
+			}
+		}
+		s = s[l:]
+		n = c
+	}
+	return n
+}
+
+// paramChild returns the param child using the constraint, or creates a new one if none was found. Children with
+// constraints are kept before the child without any constraint, so they're matched first.
+func (n *node) paramChild(re *regexp.Regexp) *node {
+	for _, c := range n.params {
+		if c.constraint == re || (c.constraint != nil && re != nil && c.constraint.String() == re.String()) {
+			return c
+		}
+	}
+	c := &node{constraint: re}
+	n.params = append(n.params, c)
+	for i := len(n.params) - 1; re != nil && i > 0 && n.params[i-1].constraint == nil; i-- {
+		n.params[i-1], n.params[i] = n.params[i], n.params[i-1]
+	}
+	return c
+}
+
+// insert inserts a route under the node. The route's path pattern must be valid (see parsePattern()).
+func (n *node) insert(r *Route) {
+	p, k := r.pattern, 0
+	for len(p) > 0 {
+		i := strings.IndexAny(p, ":*")
+		if i < 0 {
+			n = n.insertStatic(p)
+			break
+		}
+		if i > 0 {
+			n = n.insertStatic(p[:i])
+		}
+		end := strings.IndexByte(p[i:], '/')
+		if end < 0 {
+			end = len(p)
+		} else {
+			end += i
+		}
+		if p[i] == ':' {
+			n = n.paramChild(r.constraint(r.keys[k]))
+		} else {
+			if n.catchAll == nil {
+				n.catchAll = &node{}
+			}
+			n = n.catchAll
+		}
+		p = p[end:]
+		k++
+	}
+	if n.route != nil {
+		panic("a handler is already registered for path: " + r.path)
+	}
+	n.route = r
+}
+
+// match returns the node matching the path, where path[:i] has already been matched by the node and it's parents.
+// Static children are matched first, then params (with constraints first) and lastly catchalls. Any param values will
+// be appended to ps. If a param value doesn't match a constraint, or the rest of the path can't be matched, it will
+// backtrack and try the next child instead.
+func (n *node) match(path string, i int, ps *Params) *node {
+	rest := path[i:]
+	if rest == "" && n.route != nil {
+		return n
+	}
+	if rest != "" {
+		if c := n.staticChild(rest[0]); c != nil && strings.HasPrefix(rest, c.prefix) {
+			if found := c.match(path, i+len(c.prefix), ps); found != nil {
+				return found
+			}
+		}
+		if len(n.params) > 0 && rest[0] != '/' {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+			for _, c := range n.params {
+				if c.constraint != nil && !c.constraint.MatchString(rest[:end]) {
+					continue
+				}
+				*ps = append(*ps, Param{Value: rest[:end]})
+				if found := c.match(path, i+end, ps); found != nil {
+					return found
+				}
+				*ps = (*ps)[:len(*ps)-1]
+			}
+		}
+	}
+	if n.catchAll != nil && n.catchAll.route != nil {
+		// Catchalls are always preceded by a slash, which is included in the value
+		*ps = append(*ps, Param{Value: path[i-1:]})
+		return n.catchAll
+	}
+	return nil
+}
+
+// matchFold works like match, but matches static paths case insensitively and returns the fixed path.
+func (n *node) matchFold(path string, i int, buf []byte) ([]byte, bool) {
+	rest := path[i:]
+	if rest == "" && n.route != nil {
+		return buf, true
+	}
+	if rest != "" {
+		for _, c := range n.children {
+			if len(rest) >= len(c.prefix) && strings.EqualFold(rest[:len(c.prefix)], c.prefix) {
+				if fixed, ok := c.matchFold(path, i+len(c.prefix), append(buf, c.prefix...)); ok {
+					return fixed, true
+				}
+			}
+		}
+		if len(n.params) > 0 && rest[0] != '/' {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+			for _, c := range n.params {
+				if c.constraint != nil && !c.constraint.MatchString(rest[:end]) {
+					continue
+				}
+				if fixed, ok := c.matchFold(path, i+end, append(buf, rest[:end]...)); ok {
+					return fixed, true
+				}
+			}
+		}
+	}
+	if n.catchAll != nil && n.catchAll.route != nil {
+		return append(buf, rest...), true
+	}
+	return nil, false
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// router is a radix tree based router, with one tree per http method.
+type router struct {
+	trees     map[string]*node
+	maxParams int
+}
+
+func newRouter() *router {
+	return &router{
+		trees: make(map[string]*node),
+	}
+}
+
+// add adds a route to the router. It will cause a panic if the route's path is already registered.
+func (rt *router) add(r *Route) {
+	root := rt.trees[r.method]
+	if root == nil {
+		root = &node{}
+		rt.trees[r.method] = root
+	}
+	root.insert(r)
+	if len(r.keys) > rt.maxParams {
+		rt.maxParams = len(r.keys)
+	}
+}
+
+// lookup returns the route registered for the method and path, or nil if none was found.
+// Any params will be appended to ps.
+func (rt *router) lookup(method, path string, ps *Params) *Route {
+	root := rt.trees[method]
+	if root == nil {
+		return nil
+	}
+	start := len(*ps)
+	n := root.match(path, 0, ps)
+	if n == nil {
+		return nil
+	}
+	for i, key := range n.route.keys {
+		(*ps)[start+i].Key = key
+	}
+	return n.route
+}
+
+// redirect returns a path to redirect to, if a route was found for the path with or without a trailing slash, or
+// for the cleaned and case insensitive path.
+func (rt *router) redirect(method, p string) (string, bool) {
+	root := rt.trees[method]
+	if root == nil || method == http.MethodConnect || p == "/" {
+		return "", false
+	}
+
+	var ps Params
+	fixed := toggleTrailingSlash(p)
+	if root.match(fixed, 0, &ps) != nil {
+		return fixed, true
+	}
+
+	clean := cleanPath(p)
+	for _, fp := range []string{clean, toggleTrailingSlash(clean)} {
+		if b, ok := root.matchFold(fp, 0, nil); ok {
+			return string(b), true
+		}
+	}
+	return "", false
+}
+
+// allowed returns a comma separated list of the http methods allowed for the path (excluding the request method),
+// or an empty string if none was found.
+func (rt *router) allowed(path, method string) []string {
+	var allowed []string
+	for m, root := range rt.trees {
+		if m == method || m == http.MethodOptions {
+			continue
+		}
+		var ps Params
+		if path == "*" || root.match(path, 0, &ps) != nil {
+			allowed = append(allowed, m)
+		}
+	}
+	if len(allowed) < 1 {
+		return nil
+	}
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+	return allowed
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// routeTable contains all the registered routes for a Mux. A table will never be modified after it has been used to
+// serve requests, instead it will be replaced by an updated copy (see Mux.updateTable()).
+type routeTable struct {
+	router *router
+	routes []*Route
+	names  map[string]*Route
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		router: newRouter(),
+		names:  make(map[string]*Route),
+	}
+}
+
+// add adds a route to the table. It will cause a panic if the route's path or name is already registered.
+func (t *routeTable) add(r *Route) {
+	if r.name != "" {
+		if _, found := t.names[r.name]; found {
+			panic("route name already registered: " + r.name)
+		}
+		t.names[r.name] = r
+	}
+	t.router.add(r)
+	t.routes = append(t.routes, r)
+}
+
+// filter returns a new table with the routes for which keep returns true.
+func (t *routeTable) filter(keep func(*Route) bool) *routeTable {
+	nt := newRouteTable()
+	for _, r := range t.routes {
+		if keep(r) {
+			nt.add(r)
+		}
+	}
+	return nt
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func toggleTrailingSlash(p string) string {
+	if len(p) > 1 && p[len(p)-1] == '/' {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// cleanPath returns the shortest path equal to p, like path.Clean(), but it also makes sure the path begins with a
+// slash and it keeps any trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cp := path.Clean(p)
+	if p[len(p)-1] == '/' && cp != "/" {
+		cp += "/"
+	}
+	return cp
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+func TestRouter(t *testing.T) {
+	m := testMux(t, "", "", nil)
+	echo := func(name string) Handler {
+		return func(c *Context) error {
+			return c.String(200, fmt.Sprint(name, c.P))
+		}
+	}
+	m.Register("GET", "/", echo("root"))
+	m.Register("GET", "/users", echo("users"))
+	m.Register("GET", "/users/new", echo("new"))
+	m.Register("GET", "/users/:id", echo("user"))
+	m.Register("GET", "/users/:id/edit", echo("edit"))
+	m.Register("GET", "/users/:name/posts/:post", echo("post"))
+	m.Register("GET", "/users/*path", echo("users catchall"))
+	m.Register("GET", "/user_:name", echo("user_"))
+	m.Register("GET", "/static/*filepath", echo("static"))
+	m.Register("GET", "/src/", echo("src"))
+	m.Register("GET", "/src/*filepath", echo("src catchall"))
+	m.Register("GET", "/Case/Sensitive", echo("case"))
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/", "root[]"},
+		{"/users", "users[]"},
+		{"/users/new", "new[]"},
+		{"/users/news", "user[{id news}]"},
+		{"/users/10", "user[{id 10}]"},
+		{"/users/10/edit", "edit[{id 10}]"},
+		{"/users/10/posts/20", "post[{name 10} {post 20}]"},
+		{"/users/10/posts", "users catchall[{path /10/posts}]"},
+		{"/users/new/edit", "edit[{id new}]"},
+		{"/users/a/b/c", "users catchall[{path /a/b/c}]"},
+		{"/users/", "users catchall[{path /}]"},
+		{"/user_bob", "user_[{name bob}]"},
+		{"/static/", "static[{filepath /}]"},
+		{"/static/css/app.css", "static[{filepath /css/app.css}]"},
+		{"/src/", "src[]"},
+		{"/src/main.go", "src catchall[{filepath /main.go}]"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, tt.body)
+	}
+
+	redirects := []struct {
+		method, path string
+		status       int
+		location     string
+	}{
+		{"GET", "/user_bob/", http.StatusMovedPermanently, "/user_bob"},
+		{"GET", "/src", http.StatusMovedPermanently, "/src/"},
+		{"GET", "/static", http.StatusMovedPermanently, "/static/"},
+		{"GET", "/case/sensitive", http.StatusMovedPermanently, "/Case/Sensitive"},
+		{"GET", "/CASE/SENSITIVE/", http.StatusMovedPermanently, "/Case/Sensitive"},
+		{"GET", "/Case/../Case/Sensitive", http.StatusMovedPermanently, "/Case/Sensitive"},
+		{"GET", "/USERS/10?q=1", http.StatusMovedPermanently, "/users/10?q=1"},
+		{"GET", "/missing", http.StatusNotFound, ""},
+		{"POST", "/users", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range redirects {
+		resp := assert.DoRequest(t, m, tt.method, tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Header(t, resp, "Location", tt.location)
+	}
+
+	t.Run("redirect other methods", func(t *testing.T) {
+		m.Register("POST", "/form", echo("form"))
+		resp := assert.DoRequest(t, m, "POST", "/form/", nil, nil)
+		assert.StatusCode(t, resp, http.StatusTemporaryRedirect)
+		assert.Header(t, resp, "Location", "/form")
+	})
+	t.Run("server wide options", func(t *testing.T) {
+		resp := assert.DoRequest(t, m, "OPTIONS", "*", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Allow", "GET, OPTIONS, POST")
+	})
+}
+
+func TestRouterPanics(t *testing.T) {
+	h := func(c *Context) error { return nil }
+	tests := []string{
+		"",
+		"missing/slash",
+		"/dup",
+		"/files/*path/more",
+		"/files*path",
+		"/users/:",
+		"/users/:id:name",
+	}
+	for _, path := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for path %q", path)
+				}
+			}()
+			m := testMux(t, "GET", "/dup", h)
+			m.Register("GET", path, h)
+		}()
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"a", "/a"},
+		{"/a/", "/a/"},
+		{"//a//b/", "/a/b/"},
+		{"/a/./b/../c", "/a/c"},
+		{"/../a", "/a"},
+	}
+	for _, tt := range tests {
+		if got := cleanPath(tt.path); got != tt.want {
+			t.Errorf("got clean path %q for %q, wanted %q", got, tt.path, tt.want)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func newBenchmarkRouter(b *testing.B) *Mux {
+	m := newBenchmarkMux(b, "", "", nil)
+	h := func(c *Context) error { return nil }
+	for _, p := range []string{
+		"/", "/hello", "/users", "/users/new", "/users/:id", "/users/:id/edit", "/users/:id/posts/:post",
+		"/users/*path", "/static/*filepath", "/api/v1/items", "/api/v1/items/:id", "/api/v2/items/:id",
+	} {
+		m.Register("GET", p, h)
+	}
+	return m
+}
+
+func benchmarkRouter(b *testing.B, path string) {
+	m := newBenchmarkRouter(b)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkRouterStatic(b *testing.B) {
+	benchmarkRouter(b, "/users/new")
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	benchmarkRouter(b, "/users/10")
+}
+
+func BenchmarkRouterParams(b *testing.B) {
+	benchmarkRouter(b, "/users/10/posts/20")
+}
+
+func BenchmarkRouterCatchAll(b *testing.B) {
+	benchmarkRouter(b, "/static/css/app.css")
+}
+
+func BenchmarkRouterBacktrack(b *testing.B) {
+	benchmarkRouter(b, "/users/10/posts")
+}
@@ -0,0 +1,320 @@
+// Package sessions provides server side sessions for a web.Mux, using a middleware that loads and saves the session
+// data for each request. The session ID is kept in a cookie, while the data is kept in a Store.
+//
+// Session values are encoded using encoding/gob, so any custom types must be registered with gob.Register().
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+	"time"
+
+	"github.com/lmas/web"
+	"github.com/pkg/errors"
+)
+
+// Size of the random session IDs (in bytes, before base64 encoding)
+const idSize = 32
+
+// Used by tests to control the time
+var timeNow = time.Now
+
+// Options contains optional settings for the sessions middleware.
+type Options struct {
+	// Store keeps the session data and is required, like a MemoryStore or a FileStore. The store isn't closed by the
+	// middleware, so the caller should close it when it's not used anymore (see MemoryStore.Close()).
+	Store Store
+	// CookieName is the name of the cookie with the session ID. Defaults to "session".
+	CookieName string
+	// Cookie contains the settings for the session cookie (see web.CookieOptions for the defaults).
+	Cookie *web.CookieOptions
+	// IdleTimeout expires a session after it hasn't been used for a while. Defaults to 30 minutes.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout expires a session after a fixed amount of time since it was created, no matter how often it
+	// has been used. Defaults to 24 hours.
+	AbsoluteTimeout time.Duration
+}
+
+// record is the session data, as it's encoded in the Store.
+type record struct {
+	Values   map[string]interface{}
+	Flashes  []string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+// Session contains the data for a single client. It's not safe for concurrent use.
+type Session struct {
+	id         string
+	rec        record
+	stored     bool // The session exists in the store
+	modified   bool
+	regenerate bool
+	destroyed  bool
+	saved      bool // The session has been saved for this request
+	opt        *Options
+}
+
+// ID returns the session ID, or an empty string for a new session that hasn't been saved yet.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Get returns a value from the session, or nil if it wasn't found.
+func (s *Session) Get(key string) interface{} {
+	return s.rec.Values[key]
+}
+
+// Set sets a value in the session.
+func (s *Session) Set(key string, value interface{}) {
+	if s.rec.Values == nil {
+		s.rec.Values = make(map[string]interface{})
+	}
+	s.rec.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes a value from the session.
+func (s *Session) Delete(key string) {
+	if _, found := s.rec.Values[key]; found {
+		delete(s.rec.Values, key)
+		s.modified = true
+	}
+}
+
+// AddFlash adds a flash message, which will be kept in the session until it's read with Flashes().
+func (s *Session) AddFlash(msg string) {
+	s.rec.Flashes = append(s.rec.Flashes, msg)
+	s.modified = true
+}
+
+// Flashes returns all flash messages and removes them from the session.
+func (s *Session) Flashes() []string {
+	f := s.rec.Flashes
+	if len(f) > 0 {
+		s.rec.Flashes = nil
+		s.modified = true
+	}
+	return f
+}
+
+// Regenerate gives the session a new ID when it's saved, while keeping it's data. The old ID will be removed from
+// the store. It should be called whenever the client's privileges changes, like when logging in or out, to prevent
+// session fixation attacks.
+func (s *Session) Regenerate() {
+	s.regenerate = true
+	s.modified = true
+}
+
+// Destroy removes the session from the store and the client, when it's saved. Any later changes to the session are
+// ignored for the current request.
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// Created returns the time when the session was first created.
+func (s *Session) Created() time.Time {
+	return s.rec.Created
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Context key for the session
+var sessionKey = web.NewKey[*Session]("sessions.session")
+
+// Get returns the session for the request, or nil if the sessions middleware hasn't been used.
+func Get(c *web.Context) *Session {
+	s, _ := web.Value(c, sessionKey)
+	return s
+}
+
+// Middleware returns a middleware that loads the session for each request, which is then available using Get().
+// The session is saved before the response is written, or when the handler returns.
+// NOTE: it will cause a panic if no Store has been set.
+func Middleware(opt *Options) web.Middleware {
+	if opt == nil || opt.Store == nil {
+		panic("sessions: a Store is required")
+	}
+	if opt.CookieName == "" {
+		opt.CookieName = "session"
+	}
+	if opt.IdleTimeout <= 0 {
+		opt.IdleTimeout = 30 * time.Minute
+	}
+	if opt.AbsoluteTimeout <= 0 {
+		opt.AbsoluteTimeout = 24 * time.Hour
+	}
+
+	return func(next web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			s, err := load(c, opt)
+			if err != nil {
+				return err
+			}
+			web.SetValue(c, sessionKey, s)
+			w := &saveWriter{ResponseWriter: c.W, c: c, s: s}
+			c.W = w
+			err = next(c)
+			c.W = w.ResponseWriter
+			if serr := w.save(); serr != nil && err == nil {
+				return serr
+			}
+			return err
+		}
+	}
+}
+
+// load returns the session for the ID in the request's cookie, or a new session if it wasn't found or has expired.
+func load(c *web.Context, opt *Options) (*Session, error) {
+	now := timeNow()
+	s := &Session{
+		opt: opt,
+		rec: record{Created: now, LastSeen: now},
+	}
+	id, err := c.GetCookie(opt.CookieName)
+	if err != nil || !validID(id) {
+		return s, nil
+	}
+	data, err := opt.Store.Load(id)
+	if err == ErrNotFound {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "loading session")
+	}
+
+	var rec record
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&rec); err != nil {
+		return nil, errors.Wrap(err, "decoding session")
+	}
+	if now.Sub(rec.LastSeen) > opt.IdleTimeout || now.Sub(rec.Created) > opt.AbsoluteTimeout {
+		if err := opt.Store.Delete(id); err != nil {
+			return nil, errors.Wrap(err, "deleting expired session")
+		}
+		return s, nil
+	}
+	rec.LastSeen = now
+	s.id = id
+	s.rec = rec
+	s.stored = true
+	return s, nil
+}
+
+// save saves the session and sets the session cookie, if the session has been changed or already exists in the
+// store (to update it's idle timeout).
+func (s *Session) save(c *web.Context) error {
+	opt := s.opt
+	if s.destroyed {
+		if s.stored {
+			if err := opt.Store.Delete(s.id); err != nil {
+				return errors.Wrap(err, "deleting session")
+			}
+			c.DeleteCookie(opt.CookieName, opt.Cookie)
+		}
+		return nil
+	}
+	if !s.stored && !s.modified {
+		return nil // Don't store empty sessions
+	}
+
+	if s.id == "" || s.regenerate {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		if s.stored {
+			if err := opt.Store.Delete(s.id); err != nil {
+				return errors.Wrap(err, "deleting old session")
+			}
+		}
+		s.id = id
+		s.regenerate = false
+		if err := c.SetCookie(opt.CookieName, id, opt.Cookie); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.rec); err != nil {
+		return errors.Wrap(err, "encoding session")
+	}
+	expires := s.rec.LastSeen.Add(opt.IdleTimeout)
+	if abs := s.rec.Created.Add(opt.AbsoluteTimeout); abs.Before(expires) {
+		expires = abs
+	}
+	if err := opt.Store.Save(s.id, buf.Bytes(), expires); err != nil {
+		return errors.Wrap(err, "saving session")
+	}
+	s.stored = true
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, idSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating session id")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validID returns true if the ID looks like one created by newID(), so it's safe to use as a file name etc.
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idSize) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		b := id[i]
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-' || b == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// saveWriter saves the session before the response headers are written, so the session cookie can still be set.
+type saveWriter struct {
+	http.ResponseWriter
+	c   *web.Context
+	s   *Session
+	err error
+}
+
+func (w *saveWriter) save() error {
+	if !w.s.saved {
+		w.s.saved = true
+		w.err = w.s.save(w.c)
+	}
+	return w.err
+}
+
+func (w *saveWriter) beforeWrite() {
+	if err := w.save(); err != nil {
+		w.c.Log("Error: %s", err)
+	}
+}
+
+func (w *saveWriter) WriteHeader(status int) {
+	w.beforeWrite()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *saveWriter) Write(b []byte) (int, error) {
+	w.beforeWrite()
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError saves the session before flushing the wrapped ResponseWriter, when used by http.ResponseController (or
+// web.Context.Flush()). It returns an error if the wrapped ResponseWriter can't be flushed.
+func (w *saveWriter) FlushError() error {
+	w.beforeWrite()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped ResponseWriter, so web.Context can find any optional interfaces it implements.
+func (w *saveWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,225 @@
+package sessions
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/web"
+	"github.com/lmas/web/internal/assert"
+)
+
+func newSessionMux(t *testing.T, opt *Options) *web.Mux {
+	m := web.NewMux(&web.MuxOptions{
+		Middlewares: []web.Middleware{Middleware(opt)},
+	})
+	m.Register("GET", "/set", func(c *web.Context) error {
+		Get(c).Set("key", c.R.URL.Query().Get("v"))
+		return c.String(200, "ok")
+	})
+	m.Register("GET", "/get", func(c *web.Context) error {
+		v, _ := Get(c).Get("key").(string)
+		return c.String(200, v)
+	})
+	m.Register("GET", "/login", func(c *web.Context) error {
+		s := Get(c)
+		s.Regenerate()
+		s.Set("user", "bob")
+		s.AddFlash("welcome")
+		s.AddFlash("bob")
+		return c.Empty(200)
+	})
+	m.Register("GET", "/flashes", func(c *web.Context) error {
+		return c.String(200, strings.Join(Get(c).Flashes(), ","))
+	})
+	m.Register("GET", "/logout", func(c *web.Context) error {
+		Get(c).Destroy()
+		return c.Empty(200)
+	})
+	return m
+}
+
+// request does a request with the session cookie, returning the response body and the new cookie if it was changed.
+func request(t *testing.T, m *web.Mux, path, cookie string) (string, string) {
+	t.Helper()
+	h := http.Header{}
+	if cookie != "" {
+		h.Set("Cookie", "session="+cookie)
+	}
+	resp := assert.DoRequest(t, m, "GET", path, h, nil)
+	assert.StatusCode(t, resp, 200)
+	for _, c := range resp.Cookies() {
+		if c.Name == "session" {
+			cookie = c.Value
+		}
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), cookie
+}
+
+func TestSessions(t *testing.T) {
+	store := NewMemoryStore(0)
+	defer store.Close()
+	m := newSessionMux(t, &Options{Store: store})
+
+	body, cookie := request(t, m, "/get", "")
+	if body != "" || cookie != "" {
+		t.Errorf("got body %q and cookie %q, wanted no session", body, cookie)
+	}
+
+	_, cookie = request(t, m, "/set?v=hello", "")
+	if !validID(cookie) {
+		t.Fatalf("got invalid session cookie %q", cookie)
+	}
+	body, next := request(t, m, "/get", cookie)
+	if body != "hello" || next != cookie {
+		t.Errorf("got body %q and cookie %q, wanted hello and %q", body, next, cookie)
+	}
+	body, _ = request(t, m, "/get", "invalid")
+	if body != "" {
+		t.Errorf("got body %q for invalid session", body)
+	}
+
+	t.Run("regenerate", func(t *testing.T) {
+		_, login := request(t, m, "/login", cookie)
+		if login == cookie || !validID(login) {
+			t.Fatalf("got cookie %q, wanted a new session id", login)
+		}
+		if body, _ := request(t, m, "/get", cookie); body != "" {
+			t.Errorf("old session id still valid")
+		}
+		if body, _ := request(t, m, "/get", login); body != "hello" {
+			t.Errorf("got body %q, wanted session data to be kept", body)
+		}
+
+		if body, _ := request(t, m, "/flashes", login); body != "welcome,bob" {
+			t.Errorf("got flashes %q", body)
+		}
+		if body, _ := request(t, m, "/flashes", login); body != "" {
+			t.Errorf("got flashes %q, wanted them removed after reading", body)
+		}
+
+		h := http.Header{}
+		h.Set("Cookie", "session="+login)
+		resp := assert.DoRequest(t, m, "GET", "/logout", h, nil)
+		if c := resp.Header.Get("Set-Cookie"); !strings.HasPrefix(c, "session=; Path=/; Expires=Thu, 01 Jan 1970") {
+			t.Errorf("got cookie %q, wanted it deleted", c)
+		}
+		if body, _ := request(t, m, "/get", login); body != "" {
+			t.Errorf("session still valid after logout")
+		}
+	})
+
+	t.Run("missing store", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for missing store")
+			}
+		}()
+		Middleware(&Options{})
+	})
+}
+
+func TestTimeouts(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	store := NewFileStore(t.TempDir())
+	m := newSessionMux(t, &Options{
+		Store:           store,
+		IdleTimeout:     time.Minute,
+		AbsoluteTimeout: 3 * time.Minute,
+	})
+	_, cookie := request(t, m, "/set?v=hello", "")
+
+	// Keep the session alive until it reaches the absolute timeout
+	for i := 0; i < 3; i++ {
+		now = now.Add(50 * time.Second)
+		if body, _ := request(t, m, "/get", cookie); body != "hello" {
+			t.Errorf("got body %q at step %d, wanted hello", body, i)
+		}
+	}
+	now = now.Add(50 * time.Second)
+	if body, _ := request(t, m, "/get", cookie); body != "" {
+		t.Errorf("got body %q after absolute timeout", body)
+	}
+
+	_, cookie = request(t, m, "/set?v=hello", "")
+	now = now.Add(61 * time.Second)
+	if body, _ := request(t, m, "/get", cookie); body != "" {
+		t.Errorf("got body %q after idle timeout", body)
+	}
+
+	_, cookie = request(t, m, "/set?v=hello", "")
+	now = now.Add(2 * time.Minute)
+	if err := store.DeleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(cookie); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestStores(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	mem := NewMemoryStore(0)
+	defer mem.Close()
+	stores := map[string]Store{
+		"memory": mem,
+		"file":   NewFileStore(t.TempDir()),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			id, _ := newID()
+			if _, err := s.Load(id); err != ErrNotFound {
+				t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+			}
+			if err := s.Save(id, []byte("data"), now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := s.Load(id); err != nil || string(b) != "data" {
+				t.Errorf("got %q and error %v, wanted data", b, err)
+			}
+			if err := s.Delete(id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Load(id); err != ErrNotFound {
+				t.Errorf("got error %v after delete, wanted %v", err, ErrNotFound)
+			}
+
+			_ = s.Save(id, []byte("data"), now.Add(time.Minute))
+			now = now.Add(time.Minute)
+			if _, err := s.Load(id); err != ErrNotFound {
+				t.Errorf("got error %v after expiry, wanted %v", err, ErrNotFound)
+			}
+		})
+	}
+
+	t.Run("invalid file id", func(t *testing.T) {
+		s := stores["file"]
+		if err := s.Save("../escape", nil, now.Add(time.Minute)); err == nil {
+			t.Errorf("expected error for invalid id")
+		}
+	})
+
+	t.Run("memory cleanup", func(t *testing.T) {
+		id, _ := newID()
+		_ = mem.Save(id, []byte("data"), now.Add(-time.Second))
+		mem.DeleteExpired()
+		mem.mu.Lock()
+		n := len(mem.sessions)
+		mem.mu.Unlock()
+		if n != 0 {
+			t.Errorf("got %d sessions after cleanup, wanted 0", n)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package sessions
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by a Store when a session doesn't exist or has expired.
+var ErrNotFound = errors.New("session not found")
+
+// Store is used for loading and saving the encoded session data. It must be safe for concurrent use.
+// Implement this interface to keep the sessions in a database or cache.
+type Store interface {
+	// Load returns the data for a session, or ErrNotFound if it doesn't exist or has expired.
+	Load(id string) ([]byte, error)
+	// Save creates or updates a session, which may be removed by the store after it expires.
+	Save(id string, data []byte, expires time.Time) error
+	// Delete removes a session. It's not an error if it doesn't exist.
+	Delete(id string) error
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore keeps the sessions in memory, so they will be lost when the program stops.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewMemoryStore returns a new MemoryStore, which removes expired sessions at each cleanup interval.
+// Defaults to an interval of 1 minute, if set to 0. Use Close() to stop the cleanup.
+func NewMemoryStore(cleanup time.Duration) *MemoryStore {
+	if cleanup <= 0 {
+		cleanup = time.Minute
+	}
+	s := &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		stop:     make(chan struct{}),
+	}
+	go s.cleanup(cleanup)
+	return s
+}
+
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.DeleteExpired()
+		}
+	}
+}
+
+// Close stops the cleanup of expired sessions.
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+// DeleteExpired removes all expired sessions.
+func (s *MemoryStore) DeleteExpired() {
+	now := timeNow()
+	s.mu.Lock()
+	for id, e := range s.sessions {
+		if !now.Before(e.expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// Load implements the Store interface.
+func (s *MemoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	e, found := s.sessions[id]
+	s.mu.Unlock()
+	if !found || !timeNow().Before(e.expires) {
+		return nil, ErrNotFound
+	}
+	return e.data, nil
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(id string, data []byte, expires time.Time) error {
+	b := make([]byte, len(data))
+	copy(b, data)
+	s.mu.Lock()
+	s.sessions[id] = memoryEntry{b, expires}
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// File extension used for the session files
+const fileExt = ".session"
+
+// FileStore keeps each session in a separate file, inside a directory. Each file starts with the expiry time (as
+// unix seconds) followed by the session data.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a new FileStore, creating the directory if it doesn't exist.
+// NOTE: it will cause a panic if the directory can't be created.
+func NewFileStore(dir string) *FileStore {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		panic(err)
+	}
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if !validID(id) {
+		return "", errors.Errorf("invalid session id: %q", id)
+	}
+	return filepath.Join(s.dir, id+fileExt), nil
+}
+
+// Load implements the Store interface.
+func (s *FileStore) Load(id string) ([]byte, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if len(b) < 8 {
+		return nil, errors.Errorf("invalid session file: %s", p)
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	if !timeNow().Before(expires) {
+		return nil, ErrNotFound
+	}
+	return b[8:], nil
+}
+
+// Save implements the Store interface. The session file is replaced atomically, so concurrent loads won't see a
+// partially written file.
+func (s *FileStore) Save(id string, data []byte, expires time.Time) error {
+	p, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(expires.Unix()))
+	_, err = f.Write(b[:])
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete implements the Store interface.
+func (s *FileStore) Delete(id string) error {
+	p, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes all expired session files. It should be called periodically, as expired sessions are
+// otherwise only removed when a client tries to use them.
+func (s *FileStore) DeleteExpired() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		id := strings.TrimSuffix(fi.Name(), fileExt)
+		if fi.IsDir() || id == fi.Name() || !validID(id) {
+			continue
+		}
+		if _, err := s.Load(id); err == ErrNotFound {
+			if err := s.Delete(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
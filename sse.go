@@ -0,0 +1,188 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server-Sent Events
+// For more information, see: https://html.spec.whatwg.org/multipage/server-sent-events.html
+
+// Event is a single Server-Sent Event. Only Data is required, empty fields won't be sent.
+type Event struct {
+	// ID sets the client's last event ID, which will be sent back in the "Last-Event-ID" header when the client
+	// reconnects.
+	ID string
+	// Event is the event type, which defaults to "message" in the client.
+	Event string
+	// Data is the event payload and may contain multiple lines.
+	Data string
+	// Retry sets the client's reconnection time.
+	Retry time.Duration
+}
+
+// EventStreamOptions contains optional settings for an EventStream.
+type EventStreamOptions struct {
+	// Heartbeat sets the interval for sending comments to the client while EventStream.Run() is idle, to keep the
+	// connection alive through proxies. Defaults to 15 seconds.
+	Heartbeat time.Duration
+	// WriteTimeout sets a deadline for each write to the client, replacing the http.Server's WriteTimeout so the
+	// stream can stay open for longer. Defaults to 30 seconds.
+	WriteTimeout time.Duration
+	// Retry sets the client's reconnection time, which is sent when the stream is opened.
+	Retry time.Duration
+}
+
+// EventStream sends Server-Sent Events to a client. It's not safe for concurrent use.
+type EventStream struct {
+	c   *Context
+	opt *EventStreamOptions
+	buf []byte
+}
+
+// EventStream opens a new stream of Server-Sent Events, by sending the "text/event-stream" headers to the client.
+// It returns ErrNotSupported if the response writer can't be flushed.
+//
+// The write deadline set by a http.Server's WriteTimeout will be replaced with a new deadline for each write (see
+// Context.SetWriteDeadline()).
+func (c *Context) EventStream(opt *EventStreamOptions) (*EventStream, error) {
+	o := EventStreamOptions{}
+	if opt != nil {
+		o = *opt // Don't modify the caller's options when setting the defaults
+	}
+	opt = &o
+	if opt.Heartbeat <= 0 {
+		opt.Heartbeat = 15 * time.Second
+	}
+	if opt.WriteTimeout <= 0 {
+		opt.WriteTimeout = 30 * time.Second
+	}
+	s := &EventStream{
+		c:   c,
+		opt: opt,
+	}
+
+	h := c.W.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // Disables buffering in nginx
+	s.extendDeadline()
+	c.W.WriteHeader(http.StatusOK)
+	if opt.Retry > 0 {
+		s.buf = appendRetry(s.buf[:0], opt.Retry)
+		if _, err := c.W.Write(s.buf); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LastEventID returns the ID of the last event received by the client, taken from the "Last-Event-ID" header when
+// the client is reconnecting. It can be used to resume the stream.
+func (s *EventStream) LastEventID() string {
+	return s.c.GetHeader("Last-Event-ID")
+}
+
+// Done returns a channel that's closed when the request's context is cancelled, like when the client disconnects or
+// the server is shutting down.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.c.R.Context().Done()
+}
+
+// Send sends an event and flushes it to the client. Newlines in the ID and Event fields are removed.
+func (s *EventStream) Send(e Event) error {
+	if err := s.c.R.Context().Err(); err != nil {
+		return err
+	}
+	b := s.buf[:0]
+	if e.ID != "" {
+		b = appendField(b, "id", stripNewlines(e.ID))
+	}
+	if e.Event != "" {
+		b = appendField(b, "event", stripNewlines(e.Event))
+	}
+	if e.Retry > 0 {
+		b = appendRetry(b, e.Retry)
+	}
+	data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b = appendField(b, "data", line)
+	}
+	b = append(b, '\n')
+	s.buf = b
+	return s.write(b)
+}
+
+// Comment sends a comment, which is ignored by the client but can be used to keep the connection alive.
+func (s *EventStream) Comment(msg string) error {
+	s.buf = append(append(append(s.buf[:0], ": "...), stripNewlines(msg)...), "\n\n"...)
+	return s.write(s.buf)
+}
+
+// Run sends the events from a channel, until the channel is closed or the request's context is cancelled (in which
+// case it returns nil). Heartbeat comments are sent while there's no events.
+func (s *EventStream) Run(events <-chan Event) error {
+	t := time.NewTicker(s.opt.Heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(e); err != nil {
+				return s.ignoreCancelled(err)
+			}
+			t.Reset(s.opt.Heartbeat)
+		case <-t.C:
+			if err := s.Comment("heartbeat"); err != nil {
+				return s.ignoreCancelled(err)
+			}
+		}
+	}
+}
+
+func (s *EventStream) ignoreCancelled(err error) error {
+	if s.c.R.Context().Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (s *EventStream) write(b []byte) error {
+	s.extendDeadline()
+	if _, err := s.c.W.Write(b); err != nil {
+		return err
+	}
+	return s.c.Flush()
+}
+
+func (s *EventStream) extendDeadline() {
+	// Don't care if it's not supported, we'll still try to keep on streaming until the server times out
+	_ = s.c.SetWriteDeadline(time.Now().Add(s.opt.WriteTimeout))
+}
+
+func appendField(b []byte, name, value string) []byte {
+	b = append(b, name...)
+	b = append(b, ": "...)
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+func appendRetry(b []byte, d time.Duration) []byte {
+	return appendField(b, "retry", strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+func stripNewlines(s string) string {
+	if strings.ContainsAny(s, "\r\n") {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	opt := &EventStreamOptions{
+		Heartbeat: 50 * time.Millisecond,
+		Retry:     time.Second,
+	}
+	m := testMux(t, "GET", "/events", func(c *Context) error {
+		s, err := c.EventStream(opt)
+		if err != nil {
+			return err
+		}
+		if err := s.Send(Event{ID: s.LastEventID() + "1", Event: "up\ndate", Data: "hello\r\nworld"}); err != nil {
+			return err
+		}
+		events := make(chan Event)
+		go func() {
+			time.Sleep(250 * time.Millisecond) // Longer than the server's WriteTimeout
+			events <- Event{Data: "bye"}
+			close(events)
+		}()
+		return s.Run(events)
+	})
+	srv := httptest.NewUnstartedServer(m)
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+	want := "retry: 1000\nid: 41\nevent: update\ndata: hello\ndata: world\n\n"
+	if !strings.HasPrefix(body, want) {
+		t.Errorf("got body %q, wanted prefix %q", body, want)
+	}
+	if !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("got body %q, wanted heartbeats", body)
+	}
+	if !strings.HasSuffix(body, "data: bye\n\n") {
+		t.Errorf("got body %q, wanted the last event", body)
+	}
+	if opt.WriteTimeout != 0 {
+		t.Errorf("got WriteTimeout %s, wanted the options to be left unmodified", opt.WriteTimeout)
+	}
+}
+
+func TestEventStreamCancel(t *testing.T) {
+	done := make(chan error, 1)
+	m := testMux(t, "GET", "/events", func(c *Context) error {
+		s, err := c.EventStream(nil)
+		if err != nil {
+			return err
+		}
+		err = s.Run(make(chan Event))
+		done <- err
+		return err
+	})
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %q, wanted nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event stream didn't stop after the client disconnected")
+	}
+}
@@ -0,0 +1,484 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DotfilePolicy decides how to handle requests for dotfiles, which are any files or directories with a name starting
+// with a dot (like ".git" or ".env").
+type DotfilePolicy int
+
+const (
+	// DotfilesIgnore responds with '404 not found', as if the files didn't exist.
+	DotfilesIgnore DotfilePolicy = iota
+	// DotfilesDeny responds with '403 forbidden'.
+	DotfilesDeny
+	// DotfilesAllow serves the files like any other file.
+	DotfilesAllow
+)
+
+// StaticOptions contains optional settings for serving static files, see Mux.StaticWithOptions() and
+// Context.FileWithOptions().
+//
+// The Allow and Deny lists contains glob patterns (see path.Match) which are matched against the file path and each
+// of it's parent directories, relative to the root of the file system and without a leading slash. A pattern with a
+// slash must match the whole path (like "assets/*.map"), while a pattern without any slashes only matches the last
+// part of the path (like "*.bak" or ".well-known").
+type StaticOptions struct {
+	// IndexFiles is a list of file names to try, in order, when a directory is requested. Defaults to "index.html".
+	IndexFiles []string
+	// Listing shows a list of the files in a directory, if there's no index file. Any hidden files are not listed.
+	Listing bool
+	// ListingTemplate replaces the default template for the listing. It's executed with a *DirListing as data.
+	ListingTemplate *template.Template
+
+	// Dotfiles sets the policy for dotfiles. Defaults to DotfilesIgnore.
+	Dotfiles DotfilePolicy
+	// Deny is a list of patterns for files that should be ignored, responding with '404 not found'.
+	Deny []string
+	// Allow is a list of patterns for files or directories that should be served, even if they're denied by the Deny
+	// list or the dotfile policy. It only applies to the matching file or directory itself and not to the other parts
+	// of the path, so for example ".well-known" allows ACME challenges and security.txt, while any dotfiles inside it
+	// (or other dotfiles like ".git/security.txt") are still hidden.
+	Allow []string
+
+	// Fallback is a file that will be served instead of responding with '404 not found', for any missing paths
+	// without a file extension. It's used for single page apps (SPA) which handles the routing on the client side,
+	// like "index.html". Missing assets (like "app.js") will still get a 404.
+	Fallback string
+	// CacheControl is a map of file extensions (like ".css") to Cache-Control headers, for the served files. Use "*"
+	// to set a default header for any other extensions.
+	CacheControl map[string]string
+
+	// Assets resolves fingerprinted paths (see AssetManifest) to the original files, which are then served with an
+	// immutable Cache-Control header (overriding CacheControl). Only requests under the manifest's URL prefix are
+	// resolved, so the files should be served at the same prefix and from the same files the manifest was created
+	// with. The original paths can still be used as usual.
+	Assets *AssetManifest
+
+	// Precompressed serves precompressed files (like "app.js.br", "app.js.zst" or "app.js.gz") instead of the
+	// requested file, if they exist next to it and the client accepts the encoding. Brotli is preferred over zstd,
+	// which is preferred over gzip, unless the client prefers otherwise.
+	Precompressed bool
+}
+
+func (opt *StaticOptions) indexFiles() []string {
+	if opt.IndexFiles == nil {
+		return []string{"index.html"}
+	}
+	return opt.IndexFiles
+}
+
+// check returns 0 if the file path is allowed to be served, otherwise the status code for the response.
+// Each parent directory is checked first and then the file itself.
+func (opt *StaticOptions) check(fp string) int {
+	fp = strings.Trim(fp, "/")
+	if fp == "" {
+		return 0
+	}
+	parts := strings.Split(fp, "/")
+	for i, part := range parts {
+		p := strings.Join(parts[:i+1], "/")
+		if matchPatterns(opt.Allow, p) {
+			continue
+		}
+		if matchPatterns(opt.Deny, p) {
+			return http.StatusNotFound
+		}
+		if opt.Dotfiles != DotfilesAllow && strings.HasPrefix(part, ".") {
+			if opt.Dotfiles == DotfilesDeny {
+				return http.StatusForbidden
+			}
+			return http.StatusNotFound
+		}
+	}
+	return 0
+}
+
+// matchPatterns returns true if any of the patterns matches the file path (see StaticOptions).
+func matchPatterns(patterns []string, fp string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		name := fp
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(fp)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl returns the Cache-Control header for a file name, if any.
+func (opt *StaticOptions) cacheControl(name string) string {
+	if cc, found := opt.CacheControl[strings.ToLower(path.Ext(name))]; found {
+		return cc
+	}
+	return opt.CacheControl["*"]
+}
+
+// FileWithOptions works the same way as File, except it uses the optional settings (see StaticOptions) for handling
+// directories, hidden files and missing files.
+// A request for a directory without a trailing slash will be redirected to the path with a slash, so that any
+// relative links in an index file or listing will work.
+func (c *Context) FileWithOptions(fs http.FileSystem, fp string, opt *StaticOptions) error {
+	dirs := opt != nil
+	if opt == nil {
+		opt = &StaticOptions{}
+	}
+	fp = path.Clean("/" + filepath.ToSlash(fp))
+	if opt.Assets != nil && opt.Assets.served(c.R.URL.Path) {
+		if orig, ok := opt.Assets.Resolve(fp); ok {
+			fp = "/" + orig
+			o := *opt
+			o.CacheControl = map[string]string{"*": immutableCacheControl}
+			opt = &o
+		}
+	}
+	if status := opt.check(fp); status == http.StatusForbidden {
+		return c.Error(status, http.StatusText(status))
+	} else if status != 0 {
+		return c.NotFound()
+	}
+
+	f, err := fs.Open(fp)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.Log("Error: %s", err)
+		} else if opt.Fallback != "" && path.Ext(fp) == "" {
+			return c.fallback(fs, opt)
+		}
+		return c.NotFound()
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		c.Log("Error: %s", err)
+		return c.NotFound()
+	}
+	if !fi.IsDir() {
+		c.serveFile(fs, fp, f, fi, opt)
+		return nil
+	}
+	if !dirs {
+		return c.NotFound()
+	}
+
+	if !strings.HasSuffix(c.R.URL.Path, "/") {
+		u := *c.R.URL
+		u.Path, u.RawPath = u.Path+"/", ""
+		return c.Redirect(http.StatusMovedPermanently, u.String())
+	}
+	for _, name := range opt.indexFiles() {
+		ip := path.Join(fp, name)
+		if opt.check(ip) != 0 {
+			continue
+		}
+		index, err := fs.Open(ip)
+		if err != nil {
+			continue
+		}
+		defer index.Close()
+		if ifi, err := index.Stat(); err == nil && !ifi.IsDir() {
+			c.serveFile(fs, ip, index, ifi, opt)
+			return nil
+		}
+	}
+	if opt.Listing {
+		return c.dirListing(f, fp, opt)
+	}
+	return c.NotFound()
+}
+
+// fallback serves the fallback file for a missing path.
+func (c *Context) fallback(fs http.FileSystem, opt *StaticOptions) error {
+	f, err := fs.Open(opt.Fallback)
+	if err != nil {
+		c.Log("Error: %s", err)
+		return c.NotFound()
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return c.NotFound()
+	}
+	c.serveFile(fs, opt.Fallback, f, fi, opt)
+	return nil
+}
+
+// serveFile sends the contents of a file (or a precompressed version of it), using http.ServeContent to handle Range
+// and conditional requests.
+func (c *Context) serveFile(fs http.FileSystem, fp string, f http.File, fi os.FileInfo, opt *StaticOptions) {
+	if cc := opt.cacheControl(fi.Name()); cc != "" {
+		c.SetHeader("Cache-Control", cc)
+	}
+	if opt.Precompressed {
+		c.W.Header().Add("Vary", "Accept-Encoding")
+		if cf, enc := openPrecompressed(fs, fp, c.GetHeader("Accept-Encoding")); cf != nil {
+			defer cf.Close()
+			ct, err := contentType(fi.Name(), f)
+			if err == nil {
+				c.SetHeader("Content-Type", ct)
+				c.SetHeader("Content-Encoding", enc)
+				// Keep the original's modification time, so it's the same for all encodings
+				http.ServeContent(c.W, c.R, fi.Name(), fi.ModTime(), cf)
+				return
+			}
+			c.Log("Error: %s", err)
+		}
+	}
+	http.ServeContent(c.W, c.R, fi.Name(), fi.ModTime(), f)
+	// ServeContent will handle any errors with a http.Error, so we do nothing else
+}
+
+// Supported encodings for precompressed files, in order of preference
+var precompressedEncodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// openPrecompressed opens the precompressed version of a file with the best encoding accepted by the client, or
+// returns nil if there's none.
+func openPrecompressed(fs http.FileSystem, fp, acceptEncoding string) (http.File, string) {
+	if acceptEncoding == "" {
+		return nil, ""
+	}
+	accepted := parseAcceptEncoding(acceptEncoding)
+	type candidate struct {
+		enc, ext string
+		q        float64
+	}
+	var list []candidate
+	for _, e := range precompressedEncodings {
+		q, found := accepted[e.name]
+		if !found {
+			q = accepted["*"]
+		}
+		if q > 0 {
+			list = append(list, candidate{e.name, e.ext, q})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+
+	for _, cand := range list {
+		f, err := fs.Open(fp + cand.ext)
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+			return f, cand.enc
+		}
+		f.Close()
+	}
+	return nil, ""
+}
+
+// parseAcceptEncoding returns the quality values for each encoding in an "Accept-Encoding" header.
+func parseAcceptEncoding(header string) map[string]float64 {
+	list := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		enc := strings.ToLower(strings.TrimSpace(params[0]))
+		if enc == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && (p[0] == 'q' || p[0] == 'Q') && p[1] == '=' {
+				v, err := strconv.ParseFloat(p[2:], 64)
+				if err != nil || v < 0 || v > 1 {
+					v = 0
+				}
+				q = v
+			}
+		}
+		list[enc] = q
+	}
+	return list
+}
+
+// contentType returns the content type of the uncompressed file, using it's extension or sniffing it's content.
+func contentType(name string, f io.ReadSeeker) (string, error) {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct, nil
+	}
+	buf := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, buf)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// DirListing is the data used by the template for directory listings.
+type DirListing struct {
+	// Path is the URL path of the directory.
+	Path string
+	// Files contains the directory's files and sub directories, sorted with the directories first.
+	Files []DirEntry
+	// Sort is the field the files are sorted by, either "name", "size" or "time". It's set by the "sort" query
+	// parameter in the request.
+	Sort string
+	// Desc is true if the files are sorted in descending order, set by the "order=desc" query parameter.
+	Desc bool
+}
+
+// SortURL returns a relative URL for sorting the listing by a field, toggling the order if it's already sorted by
+// the same field.
+func (l *DirListing) SortURL(field string) string {
+	order := "asc"
+	if field == l.Sort && !l.Desc {
+		order = "desc"
+	}
+	return "?sort=" + url.QueryEscape(field) + "&order=" + order
+}
+
+// DirEntry is a single file or sub directory in a DirListing.
+type DirEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// URL returns the escaped, relative URL for the entry.
+func (e DirEntry) URL() string {
+	u := (&url.URL{Path: e.Name}).String()
+	if strings.Contains(e.Name, ":") {
+		u = "./" + u // Don't let it be confused with a scheme
+	}
+	if e.IsDir {
+		u += "/"
+	}
+	return u
+}
+
+// HumanSize returns the size in a human readable format, like "1.5 KB".
+func (e DirEntry) HumanSize() string {
+	if e.IsDir {
+		return "-"
+	}
+	const unit = 1024
+	if e.Size < unit {
+		return fmt.Sprintf("%d B", e.Size)
+	}
+	div, exp := int64(unit), 0
+	for n := e.Size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(e.Size)/float64(div), "KMGTPE"[exp])
+}
+
+var defaultListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead><tr>
+<th><a href="{{.SortURL "name"}}">Name</a></th>
+<th><a href="{{.SortURL "size"}}">Size</a></th>
+<th><a href="{{.SortURL "time"}}">Modified</a></th>
+</tr></thead>
+<tbody>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td>-</td><td></td></tr>
+{{end}}{{range .Files}}<tr><td><a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{.HumanSize}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+// dirListing renders a listing of the files in the directory.
+func (c *Context) dirListing(dir http.File, fp string, opt *StaticOptions) error {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		c.Log("Error: %s", err)
+		return c.NotFound()
+	}
+
+	q := c.R.URL.Query()
+	listing := &DirListing{
+		Path: c.R.URL.Path,
+		Sort: q.Get("sort"),
+		Desc: q.Get("order") == "desc",
+	}
+	if listing.Sort != "size" && listing.Sort != "time" {
+		listing.Sort = "name"
+	}
+	for _, fi := range infos {
+		if opt.check(path.Join(fp, fi.Name())) != 0 {
+			continue
+		}
+		listing.Files = append(listing.Files, DirEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			IsDir:   fi.IsDir(),
+		})
+	}
+	sortDirEntries(listing.Files, listing.Sort, listing.Desc)
+
+	t := opt.ListingTemplate
+	if t == nil {
+		t = defaultListingTemplate
+	}
+	buff := c.M.getTemplateBuff()
+	defer c.M.putTemplateBuff(buff)
+	if err := t.Execute(buff, listing); err != nil {
+		return err
+	}
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.W.WriteHeader(http.StatusOK)
+	_, err = buff.WriteTo(c.W)
+	return err
+}
+
+// sortDirEntries sorts the entries by a field, always keeping the directories first.
+func sortDirEntries(files []DirEntry, field string, desc bool) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if desc {
+			a, b = b, a
+		}
+		switch field {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "time":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		}
+		return a.Name < b.Name
+	})
+}
@@ -0,0 +1,314 @@
+package web
+
+import (
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+// Mod time for the files in the static test fixture, see staticFixture()
+var staticFixtureTime = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// staticFixture returns the shared files for the static tests, from "testdata/static". The mod times are reset first,
+// as they're used for sorting listings and checking Last-Modified headers. Precompressed files are an hour newer than
+// the other files and "files/large.txt" and "files/b&b.txt" are one and two hours older.
+func staticFixture(t *testing.T) http.FileSystem {
+	t.Helper()
+	dir := "testdata/static"
+	err := filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mod := staticFixtureTime
+		switch filepath.Ext(fp) {
+		case ".br", ".zst", ".gz":
+			mod = mod.Add(time.Hour)
+		}
+		switch filepath.Base(fp) {
+		case "large.txt":
+			mod = mod.Add(-time.Hour)
+		case "b&b.txt":
+			mod = mod.Add(-2 * time.Hour)
+		}
+		return os.Chtimes(fp, mod, mod)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return http.Dir(dir)
+}
+
+func TestStaticIndex(t *testing.T) {
+	m := NewMux(nil)
+	fs := staticFixture(t)
+	m.StaticWithOptions("/", fs, &StaticOptions{IndexFiles: []string{"index.html", "index.htm"}})
+	m.Group("/plain").Static("/", fs)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", 200, "<h1>home</h1>"},
+		{"/about/", 200, "about"},
+		{"/files/", 404, "404 page not found\n"},
+		{"/.hidden/", 404, "404 page not found\n"},
+		{"/plain/", 404, "404 page not found\n"},
+		{"/plain/index.html", 200, "<h1>home</h1>"},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+	}
+
+	resp := assert.DoRequest(t, m, "GET", "/about?x=1", nil, nil)
+	assert.StatusCode(t, resp, http.StatusMovedPermanently)
+	assert.Header(t, resp, "Location", "/about/?x=1")
+}
+
+func TestStaticListing(t *testing.T) {
+	m := NewMux(nil)
+	m.StaticWithOptions("/", staticFixture(t), &StaticOptions{Listing: true})
+
+	listing := func(query string) string {
+		t.Helper()
+		resp := assert.DoRequest(t, m, "GET", "/files/"+query, nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Content-Type", "text/html; charset=utf-8")
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+	order := func(body string, names ...string) {
+		t.Helper()
+		last := -1
+		for _, n := range names {
+			i := strings.Index(body, `href="`+n+`"`)
+			if i < 0 || i < last {
+				t.Errorf("got listing with %q out of order, wanted order %q:\n%s", n, names, body)
+				return
+			}
+			last = i
+		}
+	}
+
+	body := listing("")
+	if strings.Contains(body, ".secret") || strings.Contains(body, ".keep") {
+		t.Errorf("got dotfiles in listing:\n%s", body)
+	}
+	if !strings.Contains(body, "<td>1.5 KB</td>") || !strings.Contains(body, `href="?sort=name&amp;order=desc"`) {
+		t.Errorf("got listing without sizes or sort links:\n%s", body)
+	}
+	order(body, "docs/", "empty/", "b&amp;b.txt", "large.txt", "small.txt")
+	// The sizes of the dirs depends on the file system, so only their position before the files is checked
+	body = listing("?sort=size&order=desc")
+	order(body, "docs/", "large.txt", "b&amp;b.txt", "small.txt")
+	order(body, "empty/", "large.txt")
+	order(listing("?sort=time"), "docs/", "empty/", "b&amp;b.txt", "large.txt", "small.txt")
+
+	t.Run("custom template", func(t *testing.T) {
+		tmpl := template.Must(template.New("").Parse(`{{.Path}}:{{range .Files}} {{.Name}}{{end}}`))
+		m := NewMux(nil)
+		m.StaticWithOptions("/", staticFixture(t), &StaticOptions{Listing: true, ListingTemplate: tmpl})
+		resp := assert.DoRequest(t, m, "GET", "/files/empty/", nil, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, "/files/empty/:")
+	})
+}
+
+func TestMuxFile(t *testing.T) {
+	m := NewMux(nil)
+	m.File("/hello", "testdata/static/hello.txt")
+	resp := assert.DoRequest(t, m, "GET", "/hello", nil, nil)
+	assert.StatusCode(t, resp, http.StatusOK)
+	assert.Body(t, resp, "hello world\n")
+
+	gz, err := ioutil.ReadFile("testdata/static/hello.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := &StaticOptions{Precompressed: true, CacheControl: map[string]string{"*": "no-cache"}}
+	m.FileWithOptions("/hello-gz", http.Dir("testdata/static"), "hello.txt", opt)
+	m.Group("/group").FileWithOptions("/hello-gz", http.Dir("testdata/static"), "hello.txt", opt)
+	for _, p := range []string{"/hello-gz", "/group/hello-gz"} {
+		h := http.Header{}
+		h.Set("Accept-Encoding", "gzip")
+		resp = assert.DoRequest(t, m, "GET", p, h, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Header(t, resp, "Content-Encoding", "gzip")
+		assert.Header(t, resp, "Content-Type", "text/plain; charset=utf-8")
+		assert.Header(t, resp, "Cache-Control", "no-cache")
+		assert.Body(t, resp, string(gz))
+	}
+	resp = assert.DoRequest(t, m, "GET", "/hello-gz", nil, nil)
+	assert.Header(t, resp, "Content-Encoding", "")
+	assert.Body(t, resp, "hello world\n")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for missing file")
+		}
+	}()
+	m.FileWithOptions("/missing", http.Dir("testdata/static"), "missing.txt", nil)
+}
+
+func TestHumanSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KB",
+		5 * 1024 * 1024: "5.0 MB",
+	}
+	for size, want := range tests {
+		if got := (DirEntry{Size: size}).HumanSize(); got != want {
+			t.Errorf("got size %q for %d, wanted %q", got, size, want)
+		}
+	}
+}
+
+func TestStaticPolicy(t *testing.T) {
+	fs := staticFixture(t)
+	m := NewMux(nil)
+	m.StaticWithOptions("/", fs, &StaticOptions{
+		Allow: []string{".well-known"},
+		Deny:  []string{"*.bak", "js/*.map"},
+	})
+	m.StaticWithOptions("/txt", fs, &StaticOptions{Allow: []string{"*.txt"}, Deny: []string{"files"}})
+	m.StaticWithOptions("/deny", fs, &StaticOptions{Dotfiles: DotfilesDeny})
+	m.StaticWithOptions("/allow", fs, &StaticOptions{Dotfiles: DotfilesAllow})
+	m.StaticWithOptions("/spa", fs, &StaticOptions{
+		Fallback: "index.html",
+		CacheControl: map[string]string{
+			".js":   "public, max-age=31536000, immutable",
+			".html": "no-cache",
+			"*":     "public, max-age=3600",
+		},
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		cache  string
+	}{
+		{"/.well-known/security.txt", 200, "contact", ""},
+		{"/.well-known/.private", 404, "404 page not found\n", ""},
+		{"/txt/.hidden/notes.txt", 404, "404 page not found\n", ""},
+		{"/txt/files/small.txt", 404, "404 page not found\n", ""},
+		{"/txt/.well-known/security.txt", 404, "404 page not found\n", ""},
+		{"/txt/about/notes.txt", 200, "notes", ""},
+		{"/.hidden/config", 404, "404 page not found\n", ""},
+		{"/.env", 404, "404 page not found\n", ""},
+		{"/js/old.bak", 404, "404 page not found\n", ""},
+		{"/js/app.js.map", 404, "404 page not found\n", ""},
+		{"/js/app.js", 200, "plain js", ""},
+		{"/deny/.env", 403, "Forbidden\n", ""},
+		{"/deny/.hidden/config", 403, "Forbidden\n", ""},
+		{"/allow/.env", 200, "secret", ""},
+		{"/spa/js/app.js", 200, "plain js", "public, max-age=31536000, immutable"},
+		{"/spa/js/app.js.map", 200, "map", "public, max-age=3600"},
+		{"/spa/users/123", 200, "<h1>home</h1>", "no-cache"},
+		{"/spa/js/missing.js", 404, "404 page not found\n", ""},
+		{"/spa/.hidden/config", 404, "404 page not found\n", ""},
+	}
+	for _, tt := range tests {
+		resp := assert.DoRequest(t, m, "GET", tt.path, nil, nil)
+		assert.StatusCode(t, resp, tt.status)
+		assert.Body(t, resp, tt.body)
+		assert.Header(t, resp, "Cache-Control", tt.cache)
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.bak", "old.bak", true},
+		{"*.bak", "dir/old.bak", true},
+		{"*.bak", "dir.bak/file", false},
+		{".well-known", ".well-known", true},
+		{"/.well-known/", ".well-known", true},
+		{".well-known", ".well-known/security.txt", false},
+		{"assets/*.map", "assets/app.js.map", true},
+		{"assets/*.map", "other/assets/app.js.map", false},
+		{"assets/*", "assets/css", true},
+		{"assets/*", "assets/css/app.css", false},
+		{"*.bak", "old.txt", false},
+	}
+	for _, tt := range tests {
+		if got := matchPatterns([]string{tt.pattern}, tt.path); got != tt.match {
+			t.Errorf("got %v for pattern %q and path %q, wanted %v", got, tt.pattern, tt.path, tt.match)
+		}
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	fs := staticFixture(t)
+	jsType := mime.TypeByExtension(".js")
+	m := NewMux(nil)
+	m.StaticWithOptions("/", fs, &StaticOptions{Precompressed: true})
+	m.StaticWithOptions("/off", fs, nil)
+
+	tests := []struct {
+		path, accept string
+		body, enc    string
+		ctype        string
+	}{
+		{"/js/app.js", "", "plain js", "", jsType},
+		{"/js/app.js", "gzip, deflate, br", "brotli js", "br", jsType},
+		{"/js/app.js", "gzip, zstd", "zstd js", "zstd", jsType},
+		{"/js/app.js", "gzip;q=1, br;q=0.5", "gzip js", "gzip", jsType},
+		{"/js/app.js", "*, br;q=0", "zstd js", "zstd", jsType},
+		{"/js/app.js", "identity", "plain js", "", jsType},
+		{"/css/app.css", "gzip, br", "body{color:red}\n", "", "text/css; charset=utf-8"},
+		{"/data", "gzip", "gzip data", "gzip", "text/html; charset=utf-8"},
+		{"/", "gzip", "gzip index", "gzip", "text/html; charset=utf-8"},
+		{"/off/js/app.js", "gzip, br", "plain js", "", jsType},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.accept != "" {
+			h.Set("Accept-Encoding", tt.accept)
+		}
+		resp := assert.DoRequest(t, m, "GET", tt.path, h, nil)
+		assert.StatusCode(t, resp, http.StatusOK)
+		assert.Body(t, resp, tt.body)
+		assert.Header(t, resp, "Content-Encoding", tt.enc)
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.ctype) {
+			t.Errorf("got Content-Type %q for %s, wanted %q", ct, tt.path, tt.ctype)
+		}
+		if vary := resp.Header.Get("Vary"); (vary == "Accept-Encoding") == strings.HasPrefix(tt.path, "/off") {
+			t.Errorf("got Vary %q for %s", vary, tt.path)
+		}
+	}
+
+	t.Run("range", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Accept-Encoding", "gzip")
+		h.Set("Range", "bytes=0-3")
+		resp := assert.DoRequest(t, m, "GET", "/js/app.js", h, nil)
+		assert.StatusCode(t, resp, http.StatusPartialContent)
+		assert.Header(t, resp, "Content-Range", "bytes 0-3/7")
+		assert.Header(t, resp, "Content-Encoding", "gzip")
+		assert.Body(t, resp, "gzip")
+	})
+
+	t.Run("if modified since", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Accept-Encoding", "br")
+		h.Set("If-Modified-Since", staticFixtureTime.Format(http.TimeFormat))
+		resp := assert.DoRequest(t, m, "GET", "/js/app.js", h, nil)
+		assert.StatusCode(t, resp, http.StatusNotModified)
+		assert.Header(t, resp, "Last-Modified", staticFixtureTime.Format(http.TimeFormat))
+	})
+}
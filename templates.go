@@ -2,23 +2,51 @@ package web
 
 import (
 	"html/template"
+	"io/fs"
+	"path"
 	"path/filepath"
+
+	"github.com/pkg/errors"
 )
 
+// templateFuncs returns a FuncMap with the default template funcs, merged with the optional funcs.
+// The defaults are only placeholders and will be replaced by NewMux(), when the templates are used in MuxOptions.
+func templateFuncs(funcs template.FuncMap) template.FuncMap {
+	list := template.FuncMap{
+		"urlfor": func(string, ...interface{}) (string, error) {
+			return "", errors.New("urlfor: templates hasn't been loaded by a Mux")
+		},
+		"asset": func(string) (string, error) {
+			return "", errors.New("asset: templates hasn't been loaded by a Mux with Assets")
+		},
+		"assetSRI": func(string) (string, error) {
+			return "", errors.New("assetSRI: templates hasn't been loaded by a Mux with Assets")
+		},
+		"assetTag": func(string) (template.HTML, error) {
+			return "", errors.New("assetTag: templates hasn't been loaded by a Mux with Assets")
+		},
+	}
+	for k, v := range funcs {
+		list[k] = v
+	}
+	return list
+}
+
 // LoadTemplates is a helper for quickly loading template files from a dir (using a filepath.Glob pattern) and an
 // optional FuncMap. The returned map can be used straight away in the Options{} struct for the web handler.
 // Templates are sorted (and parsed) by their file names.
+// The default "urlfor" func is available in all templates, see Mux.URL() for usage. The "asset", "assetSRI" and
+// "assetTag" funcs are available when using MuxOptions.Assets, see AssetManifest for usage. Any custom funcs with the
+// same names will be replaced by the Mux.
 //
 // NOTE: it will cause a panic on any errors (cuz I think it's bad enough, while trying to start up the web server).
-//
-// TODO: rewrite this helper to use embed.FS instead, after go1.16 has landed in feb 2021
-//       (see https://tip.golang.org/pkg/embed/)
 func LoadTemplates(globDir string, funcs template.FuncMap) map[string]*template.Template {
 	files, err := filepath.Glob(globDir)
 	if err != nil {
 		panic(err)
 	}
 
+	funcs = templateFuncs(funcs)
 	list := make(map[string]*template.Template)
 	for _, f := range files {
 		name := filepath.Base(f)
@@ -43,6 +71,7 @@ func LoadTemplatesWithLayout(globDir string, funcs template.FuncMap) map[string]
 
 	layout := files[0]
 	layoutName := filepath.Base(layout)
+	funcs = templateFuncs(funcs)
 	list := make(map[string]*template.Template)
 	for _, f := range files[1:] {
 		t, err := template.New(layoutName).Funcs(funcs).ParseFiles(layout, f)
@@ -53,3 +82,53 @@ func LoadTemplatesWithLayout(globDir string, funcs template.FuncMap) map[string]
 	}
 	return list
 }
+
+// LoadTemplatesFS works the same way as LoadTemplates, except the template files are loaded from a fs.FS (like an
+// embed.FS) using a fs.Glob pattern.
+//
+// NOTE: it will cause a panic on any errors.
+func LoadTemplatesFS(fsys fs.FS, pattern string, funcs template.FuncMap) map[string]*template.Template {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	funcs = templateFuncs(funcs)
+	list := make(map[string]*template.Template)
+	for _, f := range files {
+		name := path.Base(f)
+		t, err := template.New(name).Funcs(funcs).ParseFS(fsys, f)
+		if err != nil {
+			panic(err)
+		}
+		list[name] = t
+	}
+	return list
+}
+
+// LoadTemplatesWithLayoutFS works the same way as LoadTemplatesWithLayout, except the template files are loaded from
+// a fs.FS (like an embed.FS) using a fs.Glob pattern.
+//
+// NOTE: the layout template will be unavailable to render stand alone
+func LoadTemplatesWithLayoutFS(fsys fs.FS, pattern string, funcs template.FuncMap) map[string]*template.Template {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		panic(err)
+	}
+	if len(files) < 1 {
+		panic("no templates matching pattern: " + pattern)
+	}
+
+	layout := files[0]
+	layoutName := path.Base(layout)
+	funcs = templateFuncs(funcs)
+	list := make(map[string]*template.Template)
+	for _, f := range files[1:] {
+		t, err := template.New(layoutName).Funcs(funcs).ParseFS(fsys, layout, f)
+		if err != nil {
+			panic(err)
+		}
+		list[path.Base(f)] = t
+	}
+	return list
+}
@@ -0,0 +1 @@
+plain js
\ No newline at end of file
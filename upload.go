@@ -0,0 +1,282 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// File uploads
+// For more information, see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Methods/POST
+
+// Default size limits for uploads
+const (
+	defaultMaxFileSize  = 10 << 20
+	defaultMaxTotalSize = 32 << 20
+)
+
+// Number of bytes used by http.DetectContentType() when sniffing the content type
+const sniffLen = 512
+
+// UploadOptions contains optional settings for handling file uploads.
+type UploadOptions struct {
+	// MaxFileSize is the max size of a single file. Defaults to 10MB.
+	MaxFileSize int64
+	// MaxTotalSize is the max size of the whole request body, including all files and form values. Defaults to 32MB.
+	MaxTotalSize int64
+	// AllowedTypes is a list of allowed content types, like "image/png" or "image/*". The content type is sniffed
+	// from the file's content, using http.DetectContentType(), and not taken from the client. Defaults to allow all.
+	AllowedTypes []string
+	// TempDir is the directory used for storing the uploaded files. Defaults to os.TempDir().
+	TempDir string
+}
+
+// UploadedFile is a file uploaded by the client, which is stored in a temporary file on disk. The temporary file is
+// removed when the request is done, so use Save() to keep it.
+type UploadedFile struct {
+	// Field is the name of the form field.
+	Field string
+	// Filename is the base name of the file, as sent by the client. It should not be trusted.
+	Filename string
+	// ContentType is the sniffed content type of the file.
+	ContentType string
+	// Size is the file size in bytes.
+	Size int64
+
+	path  string
+	saved bool // The file has been moved by Save() and must not be removed
+}
+
+// Open opens the temporary file for reading.
+func (f *UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.path)
+}
+
+// Save moves the temporary file to dst, falling back to copying it if it can't be moved (like when dst is on another
+// filesystem).
+func (f *UploadedFile) Save(dst string) error {
+	if err := os.Rename(f.path, dst); err == nil {
+		f.path, f.saved = dst, true
+		return nil
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// removeUploads removes the temporary files for any uploads in the request.
+func (c *Context) removeUploads() {
+	for _, f := range c.uploads {
+		if !f.saved {
+			os.Remove(f.path)
+		}
+	}
+	c.uploads = nil
+	c.uploaded = false
+	c.uploadErr = nil
+}
+
+// Upload returns the first uploaded file for a form field, or a '400 bad request' *Error if it's missing.
+// See FormFiles() for more info.
+func (c *Context) Upload(field string, opt *UploadOptions) (*UploadedFile, error) {
+	files, err := c.FormFiles(opt)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Field == field {
+			return f, nil
+		}
+	}
+	return nil, c.Error(http.StatusBadRequest, "missing file: "+field)
+}
+
+// FormFiles parses a "multipart/form-data" request body and returns all uploaded files. Each file is streamed to a
+// temporary file on disk, which is removed when the request is done. The other form values are available as usual,
+// from Request.FormValue() or Bind().
+//
+// It returns a '413 request entity too large' *Error if a file or the whole body is too large, or a '415 unsupported
+// media type' *Error if the body isn't a multipart form or a file's content type isn't allowed.
+// The body is only parsed once, so later calls returns the same files or error (and ignores opt).
+func (c *Context) FormFiles(opt *UploadOptions) ([]*UploadedFile, error) {
+	if c.uploaded {
+		return c.uploads, c.uploadErr
+	}
+	if c.R.MultipartForm != nil {
+		return nil, errors.New("multipart form has already been parsed")
+	}
+	if opt == nil {
+		opt = &UploadOptions{}
+	}
+	maxFile, maxTotal := opt.MaxFileSize, opt.MaxTotalSize
+	if maxFile <= 0 {
+		maxFile = defaultMaxFileSize
+	}
+	if maxTotal <= 0 {
+		maxTotal = defaultMaxTotalSize
+	}
+
+	mt, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mt != "multipart/form-data" || params["boundary"] == "" {
+		return nil, c.Error(http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType))
+	}
+	if c.R.ContentLength > maxTotal {
+		return nil, c.Error(http.StatusRequestEntityTooLarge, "upload too large")
+	}
+
+	body := &limitedReader{r: c.R.Body, n: maxTotal}
+	mr := multipart.NewReader(body, params["boundary"])
+	values := make(url.Values)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, c.uploadError(body, err)
+		}
+
+		if part.FileName() == "" {
+			b, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				return nil, c.uploadError(body, err)
+			}
+			values.Add(part.FormName(), string(b))
+			continue
+		}
+
+		err = c.saveUpload(part, maxFile, opt)
+		part.Close()
+		if err != nil {
+			return nil, c.uploadError(body, err)
+		}
+	}
+
+	c.uploaded = true
+	c.R.MultipartForm = &multipart.Form{Value: values}
+	c.R.PostForm = values
+	c.R.Form = c.R.URL.Query()
+	for k, v := range values {
+		c.R.Form[k] = append(c.R.Form[k], v...)
+	}
+	return c.uploads, nil
+}
+
+// saveUpload streams a single file to a temporary file, after checking it's content type.
+func (c *Context) saveUpload(part *multipart.Part, maxSize int64, opt *UploadOptions) error {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	ct := http.DetectContentType(head)
+	if !allowedType(ct, opt.AllowedTypes) {
+		return c.Error(http.StatusUnsupportedMediaType, "file type not allowed: "+ct)
+	}
+
+	tmp, err := os.CreateTemp(opt.TempDir, "upload-")
+	if err != nil {
+		return errors.Wrap(err, "creating upload")
+	}
+	f := &UploadedFile{
+		Field:       part.FormName(),
+		Filename:    path.Base(strings.ReplaceAll(part.FileName(), "\\", "/")),
+		ContentType: ct,
+		path:        tmp.Name(),
+	}
+	// Track the file right away, so it's removed even if the upload fails
+	c.uploads = append(c.uploads, f)
+
+	size, err := io.Copy(tmp, io.LimitReader(io.MultiReader(bytes.NewReader(head), part), maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return errors.Wrap(err, "saving upload")
+	} else if err != nil {
+		return err
+	}
+	if size > maxSize {
+		return c.Error(http.StatusRequestEntityTooLarge, "file too large: "+f.Filename)
+	}
+	f.Size = size
+	return nil
+}
+
+// uploadError removes any uploaded files and converts errors from reading a too large body to a 413 *Error, or
+// errors from parsing the body to a 400 *Error. Errors from writing the temporary files are returned as is.
+// The error is kept for any later calls to FormFiles(), as the body has already been read.
+func (c *Context) uploadError(body *limitedReader, err error) error {
+	c.removeUploads()
+	if _, ok := err.(*Error); !ok {
+		if body.exceeded {
+			err = c.Error(http.StatusRequestEntityTooLarge, "upload too large")
+		} else if _, ok := errors.Cause(err).(*os.PathError); !ok {
+			err = c.Error(http.StatusBadRequest, "invalid form body")
+		}
+	}
+	c.uploaded = true
+	c.uploadErr = err
+	return err
+}
+
+// allowedType returns true if the content type matches any of the allowed types, or if there's no allowed types.
+func allowedType(ct string, allowed []string) bool {
+	if len(allowed) < 1 {
+		return true
+	}
+	mt, _, _ := mime.ParseMediaType(ct)
+	for _, a := range allowed {
+		if a == mt || strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, a[:len(a)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// limitedReader is like io.LimitedReader, but returns an error when more than n bytes has been read instead of io.EOF.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, errUploadTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
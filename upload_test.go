@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lmas/web/internal/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// multipartBody returns a multipart form with a "name" value and a "file" with the content.
+func multipartBody(t *testing.T, filename string, content []byte) (http.Header, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("name", "bob")
+	fw, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write(content)
+	_ = w.Close()
+	h := http.Header{}
+	h.Set("Content-Type", w.FormDataContentType())
+	return h, &buf
+}
+
+func TestUpload(t *testing.T) {
+	var tmpPath string
+	m := testMux(t, "POST", "/", func(c *Context) error {
+		f, err := c.Upload("file", &UploadOptions{
+			MaxFileSize:  100,
+			MaxTotalSize: 1000,
+			AllowedTypes: []string{"image/*"},
+			TempDir:      t.TempDir(),
+		})
+		if err != nil {
+			return err
+		}
+		tmpPath = f.path
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if _, err := c.FormFiles(nil); err != nil {
+			return err
+		}
+		return c.String(200, fmt.Sprintf("%s,%s,%s,%d,%s", c.R.FormValue("name"), f.Filename, f.ContentType,
+			f.Size, b[:4]))
+	})
+
+	h, body := multipartBody(t, "../../evil.png", append(pngHeader, "0123456789abcdef"...))
+	resp := assert.DoRequest(t, m, "POST", "/", h, body)
+	assert.StatusCode(t, resp, 200)
+	assert.Body(t, resp, "bob,evil.png,image/png,24,\x89PNG")
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("got error %v, wanted temp file to be removed", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		stream  bool
+		status  int
+	}{
+		{"not allowed", []byte("hello world"), false, http.StatusUnsupportedMediaType},
+		{"file too large", append(pngHeader, bytes.Repeat([]byte("a"), 100)...), false, http.StatusRequestEntityTooLarge},
+		{"body too large", append(pngHeader, bytes.Repeat([]byte("a"), 2000)...), false, http.StatusRequestEntityTooLarge},
+		{"streamed body too large", append(pngHeader, bytes.Repeat([]byte("a"), 2000)...), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, body := multipartBody(t, "file.png", tt.content)
+			var r io.Reader = body
+			if tt.stream {
+				r = io.MultiReader(body) // Hides the content length
+			}
+			resp := assert.DoRequest(t, m, "POST", "/", h, r)
+			assert.StatusCode(t, resp, tt.status)
+		})
+	}
+
+	t.Run("not multipart", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Content-Type", "application/json")
+		resp := assert.DoRequest(t, m, "POST", "/", h, strings.NewReader("{}"))
+		assert.StatusCode(t, resp, http.StatusUnsupportedMediaType)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		_ = w.WriteField("name", "bob")
+		_ = w.Close()
+		h := http.Header{}
+		h.Set("Content-Type", w.FormDataContentType())
+		resp := assert.DoRequest(t, m, "POST", "/", h, &buf)
+		assert.StatusCode(t, resp, http.StatusBadRequest)
+		assert.Body(t, resp, "missing file: file\n")
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Content-Type", "multipart/form-data; boundary=xyz")
+		resp := assert.DoRequest(t, m, "POST", "/", h, strings.NewReader("--xyz\r\nbroken"))
+		assert.StatusCode(t, resp, http.StatusBadRequest)
+	})
+	t.Run("repeated calls", func(t *testing.T) {
+		m := testMux(t, "POST", "/", func(c *Context) error {
+			_, first := c.FormFiles(&UploadOptions{MaxFileSize: 10})
+			files, err := c.FormFiles(nil)
+			if err != first || files != nil {
+				return c.String(200, fmt.Sprintf("got files %v and error %q, wanted %q", files, err, first))
+			}
+			return err
+		})
+		h, body := multipartBody(t, "file.png", append(pngHeader, "0123456789abcdef"...))
+		resp := assert.DoRequest(t, m, "POST", "/", h, body)
+		assert.StatusCode(t, resp, http.StatusRequestEntityTooLarge)
+	})
+}
+
+func TestUploadSave(t *testing.T) {
+	dst := t.TempDir() + "/saved.png"
+	m := testMux(t, "POST", "/", func(c *Context) error {
+		f, err := c.Upload("file", nil)
+		if err != nil {
+			return err
+		}
+		return f.Save(dst)
+	})
+	h, body := multipartBody(t, "file.png", pngHeader)
+	resp := assert.DoRequest(t, m, "POST", "/", h, body)
+	assert.StatusCode(t, resp, 200)
+	b, err := ioutil.ReadFile(dst)
+	if err != nil || !bytes.Equal(b, pngHeader) {
+		t.Errorf("got saved file %q and error %v", b, err)
+	}
+}
@@ -0,0 +1,363 @@
+// Package validate validates structs using struct tags, with a set of built-in rules and optional custom rules.
+//
+// Rules are set with the "validate" tag, as a comma separated list of rule names and optional args:
+//
+//	type User struct {
+//		Name  string   `json:"name" validate:"required,min=2,max=50"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"oneof=admin user"`
+//		Tags  []string `json:"tags" validate:"max=5"`
+//	}
+//
+// The built-in rules are:
+//
+//   - required: the value must not be the zero value (or a nil pointer)
+//   - min=N and max=N: numbers must be within the limits, strings, slices and maps must have a length within them
+//   - len=N: strings, slices and maps must have the exact length
+//   - email: the value must be a plain email address, like "user@example.com"
+//   - oneof=a b c: the value must be one of the space separated values
+//   - regex=pattern: the value must match the regular expression (the pattern can't contain any commas)
+//
+// All rules, except required, are skipped for empty values. Nested structs (and pointers to structs) are validated
+// too, with their fields prefixed by the parent's name, like "address.city".
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Func is a validation rule, which is called with the field's value and the optional rule argument. It should return
+// an error with a short message, like "must be positive", if the value is invalid.
+type Func func(v reflect.Value, arg string) error
+
+// Errors is a map of field names and their validation error messages.
+type Errors map[string][]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f + " " + strings.Join(e[f], ", ")
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// FieldErrors returns the validation error messages for each field.
+// It allows web.SimpleErrorHandler to render the errors as a '422 unprocessable entity' JSON response.
+func (e Errors) FieldErrors() map[string][]string {
+	return e
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Validator validates structs using a set of rules.
+type Validator struct {
+	mu    sync.RWMutex
+	rules map[string]Func
+}
+
+// New returns a new Validator with the built-in rules.
+func New() *Validator {
+	return &Validator{
+		rules: map[string]Func{
+			"min":   minRule,
+			"max":   maxRule,
+			"len":   lenRule,
+			"email": emailRule,
+			"oneof": oneOfRule,
+			"regex": regexRule,
+		},
+	}
+}
+
+// Register adds a custom rule, which can then be used in the "validate" tags. Existing rules with the same name,
+// including the built-in rules, will be replaced.
+// NOTE: "required" is reserved and will cause a panic.
+func (v *Validator) Register(name string, f Func) {
+	if name == "" || name == "required" || strings.ContainsAny(name, ",=") {
+		panic("invalid rule name: " + name)
+	}
+	v.mu.Lock()
+	v.rules[name] = f
+	v.mu.Unlock()
+}
+
+// Struct validates a struct (or a pointer to one) and returns Errors if any fields are invalid. Unknown rules and
+// invalid rule args will return a plain error instead.
+func (v *Validator) Struct(s interface{}) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("validate: expected a struct, got %T", s)
+	}
+	errs := Errors{}
+	if err := v.validateStruct(rv, "", errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) validateStruct(rv reflect.Value, prefix string, errs Errors) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // Unexported field
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		name := prefix + fieldName(f)
+		fv := rv.Field(i)
+		if tag != "" {
+			if err := v.validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			p := prefix
+			if !f.Anonymous {
+				p = name + "."
+			}
+			if err := v.validateStruct(fv, p, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Validator) validateField(fv reflect.Value, name, tag string, errs Errors) error {
+	rules := strings.Split(tag, ",")
+	for _, r := range rules {
+		if r == "required" {
+			if fv.IsZero() {
+				errs[name] = append(errs[name], "is required")
+				return nil
+			}
+		}
+	}
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.IsZero() {
+		return nil // Skip empty values
+	}
+
+	for _, r := range rules {
+		if r == "" || r == "required" {
+			continue
+		}
+		rule, arg := r, ""
+		if i := strings.IndexByte(r, '='); i >= 0 {
+			rule, arg = r[:i], r[i+1:]
+		}
+		v.mu.RLock()
+		f, found := v.rules[rule]
+		v.mu.RUnlock()
+		if !found {
+			return errors.Errorf("validate: unknown rule %q for field %q", rule, name)
+		}
+		if err := f(fv, arg); err != nil {
+			var ie *invalidArgError
+			if errors.As(err, &ie) {
+				return errors.Errorf("validate: invalid arg for rule %q for field %q: %s", rule, name, ie.msg)
+			}
+			errs[name] = append(errs[name], err.Error())
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name used for a field in the error messages, which is taken from the "json" or "form" tags
+// or the field name itself.
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		tag := f.Tag.Get(key)
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			tag = tag[:i]
+		}
+		if tag != "" && tag != "-" {
+			return tag
+		}
+	}
+	return f.Name
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var defaultValidator = New()
+
+// Register adds a custom rule to the default Validator.
+// See Validator.Register() for more info.
+func Register(name string, f Func) {
+	defaultValidator.Register(name, f)
+}
+
+// Struct validates a struct using the default Validator.
+// See Validator.Struct() for more info.
+func Struct(s interface{}) error {
+	return defaultValidator.Struct(s)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// invalidArgError is returned by the rules when the rule's arg is invalid, which is a programming error and not a
+// validation error.
+type invalidArgError struct {
+	msg string
+}
+
+func (e *invalidArgError) Error() string {
+	return e.msg
+}
+
+// size returns the numeric value or length of v, depending on it's kind. The bool is false for unsupported kinds.
+func size(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), false, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), false, true
+	}
+	return 0, false, false
+}
+
+func parseLimit(v reflect.Value, arg string) (float64, float64, bool, error) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, 0, false, &invalidArgError{"expected a number"}
+	}
+	n, isNum, ok := size(v)
+	if !ok {
+		return 0, 0, false, &invalidArgError{"unsupported type " + v.Type().String()}
+	}
+	return n, limit, isNum, nil
+}
+
+func minRule(v reflect.Value, arg string) error {
+	n, limit, isNum, err := parseLimit(v, arg)
+	switch {
+	case err != nil:
+		return err
+	case n >= limit:
+		return nil
+	case isNum:
+		return errors.New("must be at least " + arg)
+	case v.Kind() == reflect.String:
+		return errors.New("must be at least " + arg + " characters long")
+	default:
+		return errors.New("must have at least " + arg + " items")
+	}
+}
+
+func maxRule(v reflect.Value, arg string) error {
+	n, limit, isNum, err := parseLimit(v, arg)
+	switch {
+	case err != nil:
+		return err
+	case n <= limit:
+		return nil
+	case isNum:
+		return errors.New("must be at most " + arg)
+	case v.Kind() == reflect.String:
+		return errors.New("must be at most " + arg + " characters long")
+	default:
+		return errors.New("must have at most " + arg + " items")
+	}
+}
+
+func lenRule(v reflect.Value, arg string) error {
+	n, limit, isNum, err := parseLimit(v, arg)
+	switch {
+	case err != nil:
+		return err
+	case isNum:
+		return &invalidArgError{"unsupported type " + v.Type().String()}
+	case n == limit:
+		return nil
+	case v.Kind() == reflect.String:
+		return errors.New("must be exactly " + arg + " characters long")
+	default:
+		return errors.New("must have exactly " + arg + " items")
+	}
+}
+
+func emailRule(v reflect.Value, arg string) error {
+	if v.Kind() != reflect.String {
+		return &invalidArgError{"unsupported type " + v.Type().String()}
+	}
+	s := v.String()
+	a, err := mail.ParseAddress(s)
+	if err != nil || a.Address != s {
+		return errors.New("must be a valid email address")
+	}
+	return nil
+}
+
+func oneOfRule(v reflect.Value, arg string) error {
+	values := strings.Fields(arg)
+	if len(values) < 1 {
+		return &invalidArgError{"expected a list of values"}
+	}
+	s := fmt.Sprint(v.Interface())
+	for _, val := range values {
+		if s == val {
+			return nil
+		}
+	}
+	return errors.New("must be one of: " + strings.Join(values, ", "))
+}
+
+var regexCache sync.Map
+
+func regexRule(v reflect.Value, arg string) error {
+	if v.Kind() != reflect.String {
+		return &invalidArgError{"unsupported type " + v.Type().String()}
+	}
+	re, found := regexCache.Load(arg)
+	if !found {
+		r, err := regexp.Compile(arg)
+		if err != nil {
+			return &invalidArgError{err.Error()}
+		}
+		re, _ = regexCache.LoadOrStore(arg, r)
+	}
+	if !re.(*regexp.Regexp).MatchString(v.String()) {
+		return errors.New("must match the pattern " + arg)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lmas/web"
+	"github.com/lmas/web/internal/assert"
+	"github.com/pkg/errors"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5,regex=^[0-9]+$"`
+}
+
+type testUser struct {
+	Name    string       `json:"name,omitempty" validate:"required,min=2,max=5"`
+	Email   string       `form:"email" validate:"email"`
+	Age     int          `validate:"min=18,max=99"`
+	Role    string       `json:"role" validate:"oneof=admin user"`
+	Tags    []string     `json:"tags" validate:"max=2"`
+	Nick    *string      `json:"nick" validate:"min=3"`
+	Code    string       `json:"code" validate:"even"`
+	Address *testAddress `json:"address"`
+	private string       `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	v := New()
+	v.Register("even", func(v reflect.Value, arg string) error {
+		if v.Len()%2 != 0 {
+			return errors.New("must have an even length")
+		}
+		return nil
+	})
+	short := "ab"
+
+	tests := []struct {
+		user testUser
+		want Errors
+	}{
+		{testUser{Name: "bob", Age: 20, Role: "user"}, nil},
+		{testUser{Name: "bob", Email: "bob@example.com", Tags: []string{"a"}, Code: "ab",
+			Address: &testAddress{City: "oslo", Zip: "12345"}}, nil},
+		{testUser{}, Errors{"name": {"is required"}}},
+		{testUser{Name: "b", Email: "Bob <bob@example.com>", Age: 17, Role: "root", Tags: []string{"a", "b", "c"},
+			Nick: &short, Code: "abc"}, Errors{
+			"name":  {"must be at least 2 characters long"},
+			"email": {"must be a valid email address"},
+			"Age":   {"must be at least 18"},
+			"role":  {"must be one of: admin, user"},
+			"tags":  {"must have at most 2 items"},
+			"nick":  {"must be at least 3 characters long"},
+			"code":  {"must have an even length"},
+		}},
+		{testUser{Name: "bobbybob", Age: 100, Address: &testAddress{Zip: "12a"}}, Errors{
+			"name":         {"must be at most 5 characters long"},
+			"Age":          {"must be at most 99"},
+			"address.city": {"is required"},
+			"address.zip":  {"must be exactly 5 characters long", "must match the pattern ^[0-9]+$"},
+		}},
+	}
+	for i, tt := range tests {
+		err := v.Struct(&tt.user)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("test %d: got error %q, wanted none", i, err)
+			}
+			continue
+		}
+		got, ok := err.(Errors)
+		if !ok {
+			t.Errorf("test %d: got error %#v, wanted Errors", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: got errors %v, wanted %v", i, got, tt.want)
+		}
+	}
+
+	t.Run("invalid rules", func(t *testing.T) {
+		tests := []interface{}{
+			"not a struct",
+			struct {
+				A string `validate:"missing"`
+			}{"a"},
+			struct {
+				A string `validate:"min=x"`
+			}{"a"},
+			struct {
+				A int `validate:"email"`
+			}{1},
+			struct {
+				A string `validate:"regex=("`
+			}{"a"},
+		}
+		for _, s := range tests {
+			err := Struct(s)
+			if _, ok := err.(Errors); err == nil || ok {
+				t.Errorf("got error %#v for %#v, wanted a plain error", err, s)
+			}
+		}
+	})
+
+	t.Run("error message", func(t *testing.T) {
+		err := Struct(testUser{Age: 1})
+		want := "validation failed: Age must be at least 18; name is required"
+		if err == nil || err.Error() != want {
+			t.Errorf("got error %q, wanted %q", err, want)
+		}
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	m := web.NewMux(nil)
+	m.Register("POST", "/users", func(c *web.Context) error {
+		var u testUser
+		if err := c.DecodeJSON(&u); err != nil {
+			return err
+		}
+		return errors.Wrap(Struct(u), "invalid user")
+	})
+
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	resp := assert.DoRequest(t, m, "POST", "/users", h, strings.NewReader(`{"name":"b","Age":20}`))
+	assert.StatusCode(t, resp, http.StatusUnprocessableEntity)
+	assert.Header(t, resp, "Content-Type", "application/json; charset=utf-8")
+	var got map[string][]string
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["name"][0] != "must be at least 2 characters long" {
+		t.Errorf("got unexpected field errors: %v", got)
+	}
+}
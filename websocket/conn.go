@@ -0,0 +1,447 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+// Data message types, as used by the frame opcodes.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Frame opcodes, see RFC 6455 section 5.2
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Close status codes, see RFC 6455 section 7.4.1
+const (
+	CloseNormal             = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatus           = 1005 // Never sent, only used when a close frame has no status code
+	CloseAbnormal           = 1006 // Never sent, only used when the connection was closed without a close frame
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+// Max payload size for control frames
+const maxControlSize = 125
+
+// The empty deflate block which ends each compressed message, see RFC 7692 section 7.2.1
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// ErrClosed is returned when writing to a connection after a close frame has been sent.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by Conn.ReadMessage() when the connection has been closed, either by the client sending a
+// close frame or by an invalid frame from the client.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	msg := "websocket: closed with code " + strconv.Itoa(e.Code)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Conn is an upgraded websocket connection.
+// Only one goroutine may read from the connection at a time, while the writing methods are safe for concurrent use.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	readLimit   int64
+	subprotocol string
+	compress    bool
+	onPong      func([]byte)
+
+	wmu       sync.Mutex // Protects the writer and closeSent
+	bw        *bufio.Writer
+	closeSent bool
+	readErr   error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, readLimit int64, subprotocol string, compress bool) *Conn {
+	return &Conn{
+		conn:        conn,
+		br:          br,
+		readLimit:   readLimit,
+		subprotocol: subprotocol,
+		compress:    compress,
+		bw:          bufio.NewWriter(conn),
+	}
+}
+
+// Subprotocol returns the subprotocol selected during the handshake, or an empty string if none was selected.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compression returns true if the permessage-deflate extension was negotiated during the handshake.
+func (c *Conn) Compression() bool {
+	return c.compress
+}
+
+// RemoteAddr returns the client's network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets a deadline for reading the next message. A zero time means no deadline.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets a deadline for writing messages. A zero time means no deadline.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler sets a function that will be called with the payload of each pong frame received, while reading
+// messages. It should be set before reading any messages.
+func (c *Conn) SetPongHandler(fn func(data []byte)) {
+	c.onPong = fn
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// frameHeader is the header for a single frame.
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	opcode byte
+	length int64
+	mask   [4]byte
+}
+
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&0x80 != 0
+	h.rsv1 = b[0]&0x40 != 0
+	h.opcode = b[0] & 0x0f
+	if b[0]&0x30 != 0 {
+		return h, &CloseError{CloseProtocolError, "reserved bits set"}
+	}
+	if b[1]&0x80 == 0 {
+		return h, &CloseError{CloseProtocolError, "frame not masked"}
+	}
+
+	switch l := b[1] & 0x7f; l {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8]))
+		if h.length < 0 {
+			return h, &CloseError{CloseProtocolError, "invalid frame length"}
+		}
+	default:
+		h.length = int64(l)
+	}
+	if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+		return h, err
+	}
+
+	if h.opcode >= opClose {
+		if h.opcode > opPong {
+			return h, &CloseError{CloseProtocolError, "unknown opcode"}
+		}
+		if !h.fin || h.length > maxControlSize || h.rsv1 {
+			return h, &CloseError{CloseProtocolError, "invalid control frame"}
+		}
+	} else if h.opcode > opBinary {
+		return h, &CloseError{CloseProtocolError, "unknown opcode"}
+	}
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader, buf []byte) ([]byte, error) {
+	start := len(buf)
+	if int64(cap(buf)-start) < h.length {
+		nb := make([]byte, start, start+int(h.length))
+		copy(nb, buf)
+		buf = nb
+	}
+	buf = buf[:start+int(h.length)]
+	if _, err := io.ReadFull(c.br, buf[start:]); err != nil {
+		return nil, err
+	}
+	for i := range buf[start:] {
+		buf[start+i] ^= h.mask[i%4]
+	}
+	return buf, nil
+}
+
+// ReadMessage reads the next data message from the client, while handling any control frames (ping frames are
+// answered automatically). Text messages are validated as UTF-8.
+// If the client closes the connection or sends an invalid message, a *CloseError is returned after the connection
+// has been closed. Other errors (like io.EOF) are returned as is.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	typ, msg, err := c.readMessage()
+	if err != nil {
+		if ce, ok := err.(*CloseError); ok && ce.Code != CloseNoStatus && ce.Code != CloseAbnormal {
+			_ = c.writeClose(ce.Code, ce.Reason)
+		}
+		c.conn.Close()
+		c.readErr = err
+		return 0, nil, err
+	}
+	return typ, msg, nil
+}
+
+func (c *Conn) readMessage() (MessageType, []byte, error) {
+	var typ MessageType
+	var msg []byte
+	compressed := false
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if h.opcode >= opClose {
+			payload, err := c.readPayload(h, nil)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleControl(h.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+
+		if h.opcode == opContinuation {
+			if typ == 0 {
+				return 0, nil, &CloseError{CloseProtocolError, "unexpected continuation frame"}
+			}
+			if h.rsv1 {
+				return 0, nil, &CloseError{CloseProtocolError, "reserved bits set"}
+			}
+		} else {
+			if typ != 0 {
+				return 0, nil, &CloseError{CloseProtocolError, "expected continuation frame"}
+			}
+			if h.rsv1 && !c.compress {
+				return 0, nil, &CloseError{CloseProtocolError, "reserved bits set"}
+			}
+			typ = MessageType(h.opcode)
+			compressed = h.rsv1
+		}
+
+		if int64(len(msg))+h.length > c.readLimit {
+			return 0, nil, &CloseError{CloseMessageTooBig, "message too big"}
+		}
+		if msg, err = c.readPayload(h, msg); err != nil {
+			return 0, nil, err
+		}
+		if h.fin {
+			break
+		}
+	}
+
+	if compressed {
+		var err error
+		if msg, err = c.decompress(msg); err != nil {
+			return 0, nil, err
+		}
+	}
+	if typ == TextMessage && !utf8.Valid(msg) {
+		return 0, nil, &CloseError{CloseInvalidPayload, "invalid utf-8 text"}
+	}
+	return typ, msg, nil
+}
+
+func (c *Conn) handleControl(opcode byte, payload []byte) error {
+	switch opcode {
+	case opPing:
+		err := c.writeFrame(opPong, payload, false)
+		if err == ErrClosed {
+			return nil // Pings are ignored after a close frame has been sent
+		}
+		return err
+	case opPong:
+		if c.onPong != nil {
+			c.onPong(payload)
+		}
+		return nil
+	}
+
+	// Close frame
+	switch {
+	case len(payload) == 0:
+		_ = c.writeFrame(opClose, nil, false)
+		return &CloseError{CloseNoStatus, ""}
+	case len(payload) == 1:
+		return &CloseError{CloseProtocolError, "invalid close frame"}
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	reason := string(payload[2:])
+	if !validCloseCode(code) {
+		return &CloseError{CloseProtocolError, "invalid close code"}
+	}
+	if !utf8.ValidString(reason) {
+		return &CloseError{CloseInvalidPayload, "invalid utf-8 close reason"}
+	}
+	_ = c.writeClose(code, "")
+	return &CloseError{code, reason}
+}
+
+// validCloseCode returns true for close codes allowed to be sent by the client.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= CloseNormal && code <= CloseInternalError:
+		return code != 1004 && code != CloseNoStatus && code != CloseAbnormal
+	}
+	return false
+}
+
+func (c *Conn) decompress(msg []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(msg), bytes.NewReader(deflateTail)))
+	defer r.Close()
+	var out bytes.Buffer
+	n, err := out.ReadFrom(io.LimitReader(r, c.readLimit+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, &CloseError{CloseInvalidPayload, "invalid compressed data"}
+	}
+	if n > c.readLimit {
+		return nil, &CloseError{CloseMessageTooBig, "message too big"}
+	}
+	return out.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// WriteMessage sends a data message to the client, as a single frame. Messages are compressed if the
+// permessage-deflate extension was negotiated. Text messages must be valid UTF-8.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.Errorf("websocket: invalid message type %d", typ)
+	}
+	if c.compress && len(data) > 0 {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestSpeed) // Only returns errors for invalid levels
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return c.writeFrame(byte(typ), bytes.TrimSuffix(buf.Bytes(), deflateTail), true)
+	}
+	return c.writeFrame(byte(typ), data, false)
+}
+
+// Ping sends a ping frame to the client, which should answer with a pong frame (see SetPongHandler()). The data can
+// be at most 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlSize {
+		return errors.New("websocket: ping data too large")
+	}
+	return c.writeFrame(opPing, data, false)
+}
+
+// Close sends a close frame with a status code and reason to the client and then closes the connection.
+// The reason can be at most 123 bytes.
+func (c *Conn) Close(code int, reason string) error {
+	if len(reason) > maxControlSize-2 {
+		return errors.New("websocket: close reason too large")
+	}
+	err := c.writeClose(code, reason)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *Conn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	return c.writeFrame(opClose, payload, false)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte, compressed bool) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	var h [10]byte
+	h[0] = 0x80 | opcode // FIN
+	if compressed {
+		h[0] |= 0x40 // RSV1
+	}
+	n := 2
+	switch l := len(payload); {
+	case l <= 125:
+		h[1] = byte(l)
+	case l <= 0xffff:
+		h[1] = 126
+		binary.BigEndian.PutUint16(h[2:], uint16(l))
+		n += 2
+	default:
+		h[1] = 127
+		binary.BigEndian.PutUint64(h[2:], uint64(l))
+		n += 8
+	}
+	if _, err := c.bw.Write(h[:n]); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(payload); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// isClosedErr returns true for errors caused by a closed connection.
+func isClosedErr(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrClosed)
+}
@@ -0,0 +1,206 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455), including the permessage-deflate
+// extension (RFC 7692), for handlers registered on a web.Mux.
+//
+// A simple echo handler could look like:
+//
+//	m.Register("GET", "/echo", websocket.Handler(nil, func(conn *websocket.Conn) error {
+//		for {
+//			typ, msg, err := conn.ReadMessage()
+//			if err != nil {
+//				return err
+//			}
+//			if err := conn.WriteMessage(typ, msg); err != nil {
+//				return err
+//			}
+//		}
+//	}))
+package websocket
+
+import (
+	"crypto/sha1" // #nosec G505 -- required by RFC 6455, not used for security
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lmas/web"
+	"github.com/pkg/errors"
+)
+
+// Used for creating the Sec-WebSocket-Accept header, see RFC 6455 section 1.3
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Options contains optional settings for upgrading a connection.
+type Options struct {
+	// ReadLimit is the max size (in bytes) of a message read from the client, after any decompression. Larger
+	// messages will close the connection with CloseMessageTooBig. Defaults to 1 MB.
+	ReadLimit int64
+	// CheckOrigin returns true if the request's "Origin" header is allowed. Defaults to only allow requests
+	// without an "Origin" header, or if it matches the request's host.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols is a list of supported subprotocols, in order of preference. The first one also requested by
+	// the client will be used, see Conn.Subprotocol().
+	Subprotocols []string
+	// Compression enables the permessage-deflate extension, if the client supports it.
+	Compression bool
+}
+
+// Upgrade performs the websocket handshake and takes over the client's connection, returning a new Conn.
+// The handshake errors are returned as *web.Error, with a response not sent yet. After a successful upgrade the
+// handler must not use the Context's response writer anymore and should return nil, as there's no longer a http
+// response to send errors to.
+func Upgrade(c *web.Context, opt *Options) (*Conn, error) {
+	o := Options{}
+	if opt != nil {
+		o = *opt // Don't modify the caller's options when setting the defaults, as they can be shared by requests
+	}
+	opt = &o
+	if opt.ReadLimit <= 0 {
+		opt.ReadLimit = 1 << 20
+	}
+	if opt.CheckOrigin == nil {
+		opt.CheckOrigin = checkSameOrigin
+	}
+
+	r := c.R
+	if r.Method != http.MethodGet {
+		return nil, c.Error(http.StatusMethodNotAllowed, "websocket: method not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, c.Error(http.StatusBadRequest, "websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, c.Error(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, c.Error(http.StatusBadRequest, "websocket: invalid key")
+	}
+	if !opt.CheckOrigin(r) {
+		return nil, c.Error(http.StatusForbidden, "websocket: origin not allowed")
+	}
+	protocol := selectSubprotocol(r.Header, opt.Subprotocols)
+	compress := opt.Compression && acceptDeflate(r.Header)
+
+	netConn, brw, err := c.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "websocket: hijacking connection")
+	}
+	// Clear any deadlines set by the http.Server's timeouts
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		netConn.Close()
+		return nil, errors.Wrap(err, "websocket: clearing deadlines")
+	}
+
+	b := brw.Writer
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if protocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	if compress {
+		// Using no context takeover for both sides, so every message can be compressed and decompressed without
+		// keeping any state between messages
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; " +
+			"client_no_context_takeover\r\n")
+	}
+	b.WriteString("\r\n")
+	if err := b.Flush(); err != nil {
+		netConn.Close()
+		return nil, errors.Wrap(err, "websocket: writing handshake")
+	}
+	return newConn(netConn, brw.Reader, opt.ReadLimit, protocol, compress), nil
+}
+
+// Handler returns a web.Handler that upgrades the connection and then calls fn with the new Conn. The connection is
+// closed when fn returns, with CloseNormal if fn returned nil, a *CloseError or io.EOF, or CloseInternalError for
+// other errors (which are logged using web.Context.Log()).
+func Handler(opt *Options, fn func(*Conn) error) web.Handler {
+	return func(c *web.Context) error {
+		conn, err := Upgrade(c, opt)
+		if err != nil {
+			return err
+		}
+		err = fn(conn)
+		code := CloseNormal
+		var ce *CloseError
+		if err != nil && !errors.As(err, &ce) && !isClosedErr(err) {
+			c.Log("Error: websocket: %s", err)
+			code = CloseInternalError
+		}
+		_ = conn.Close(code, "") // Don't care if the connection is already closed
+		return nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func acceptKey(key string) string {
+	h := sha1.New() // #nosec G401
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains returns true if any of the comma separated tokens in the header matches the value, case
+// insensitively.
+func headerContains(h http.Header, key, value string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(h http.Header, supported []string) string {
+	for _, s := range supported {
+		if headerContains(h, "Sec-WebSocket-Protocol", s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// acceptDeflate returns true if the client offered the permessage-deflate extension with parameters that we can
+// support. Go's compress/flate always uses the max window size, so offers limiting the server's window are declined.
+func acceptDeflate(h http.Header) bool {
+	for _, v := range h.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(v, ",") {
+			params := strings.Split(offer, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), "permessage-deflate") {
+				continue
+			}
+			ok := true
+			for _, p := range params[1:] {
+				kv := strings.SplitN(p, "=", 2)
+				switch strings.ToLower(strings.TrimSpace(kv[0])) {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				case "server_max_window_bits":
+					ok = ok && len(kv) == 2 && strings.Trim(strings.TrimSpace(kv[1]), `"`) == "15"
+				default:
+					ok = false
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/web"
+	"github.com/lmas/web/internal/assert"
+	"github.com/lmas/web/middlewares"
+)
+
+// testClient is a minimal websocket client, for testing the server side.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dial(t *testing.T, url string, headers map[string]string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", url+"/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t, conn, br, resp}
+}
+
+func (c *testClient) writeFrame(fin bool, rsv1 bool, opcode byte, payload []byte) {
+	c.t.Helper()
+	var b bytes.Buffer
+	h := opcode
+	if fin {
+		h |= 0x80
+	}
+	if rsv1 {
+		h |= 0x40
+	}
+	b.WriteByte(h)
+	switch l := len(payload); {
+	case l <= 125:
+		b.WriteByte(0x80 | byte(l))
+	case l <= 0xffff:
+		b.WriteByte(0x80 | 126)
+		_ = binary.Write(&b, binary.BigEndian, uint16(l))
+	default:
+		b.WriteByte(0x80 | 127)
+		_ = binary.Write(&b, binary.BigEndian, uint64(l))
+	}
+	mask := []byte{1, 2, 3, 4}
+	b.Write(mask)
+	for i, p := range payload {
+		b.WriteByte(p ^ mask[i%4])
+	}
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) readFrame() (byte, bool, []byte) {
+	c.t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if h[1]&0x80 != 0 {
+		c.t.Fatal("got masked frame from server")
+	}
+	l := int64(h[1] & 0x7f)
+	switch l {
+	case 126:
+		var n uint16
+		_ = binary.Read(c.br, binary.BigEndian, &n)
+		l = int64(n)
+	case 127:
+		var n uint64
+		_ = binary.Read(c.br, binary.BigEndian, &n)
+		l = int64(n)
+	}
+	payload := make([]byte, l)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return h[0] & 0x0f, h[0]&0x40 != 0, payload
+}
+
+func (c *testClient) expectClose(code int) {
+	c.t.Helper()
+	op, _, payload := c.readFrame()
+	if op != opClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		c.t.Errorf("got frame %d %q, wanted close frame with code %d", op, payload, code)
+	}
+}
+
+func closePayload(code int, reason string) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(code))
+	return append(b, reason...)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func newEchoServer(t *testing.T, opt *Options, logs io.Writer) *httptest.Server {
+	m := web.NewMux(&web.MuxOptions{
+		Middlewares: []web.Middleware{middlewares.AccessLog(log.New(logs, "", 0))},
+	})
+	m.Register("GET", "/ws", Handler(opt, func(conn *Conn) error {
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return err
+			}
+		}
+	}))
+	srv := httptest.NewServer(m)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandshake(t *testing.T) {
+	opt := &Options{Subprotocols: []string{"v2", "v1"}}
+	srv := newEchoServer(t, opt, ioutil.Discard)
+	tests := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{nil, http.StatusSwitchingProtocols},
+		{map[string]string{"Upgrade": "h2c"}, http.StatusBadRequest},
+		{map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{map[string]string{"Origin": "http://evil.example.com"}, http.StatusForbidden},
+		{map[string]string{"Origin": srv.URL}, http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		c := dial(t, srv.URL, tt.headers)
+		assert.StatusCode(t, c.resp, tt.status)
+	}
+
+	c := dial(t, srv.URL, map[string]string{"Sec-WebSocket-Protocol": "v1, v2"})
+	assert.Header(t, c.resp, "Sec-WebSocket-Accept", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	assert.Header(t, c.resp, "Sec-WebSocket-Protocol", "v2")
+	assert.Header(t, c.resp, "Sec-WebSocket-Extensions", "")
+	if opt.ReadLimit != 0 || opt.CheckOrigin != nil {
+		t.Errorf("got options %+v, wanted them to be left unmodified", opt)
+	}
+
+	t.Run("method", func(t *testing.T) {
+		m := web.NewMux(nil)
+		m.Register("POST", "/ws", Handler(nil, func(conn *Conn) error { return nil }))
+		resp := assert.DoRequest(t, m, "POST", "/ws", nil, nil)
+		assert.StatusCode(t, resp, http.StatusMethodNotAllowed)
+	})
+}
+
+// logWriter sends each log line to a channel, as the handlers can outlive the test server after hijacking.
+type logWriter chan string
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w <- string(b)
+	return len(b), nil
+}
+
+func TestMessages(t *testing.T) {
+	logs := make(logWriter, 1)
+	srv := newEchoServer(t, &Options{ReadLimit: 1000}, logs)
+	c := dial(t, srv.URL, nil)
+	assert.StatusCode(t, c.resp, http.StatusSwitchingProtocols)
+
+	c.writeFrame(true, false, opText, []byte("hello"))
+	if op, _, msg := c.readFrame(); op != opText || string(msg) != "hello" {
+		t.Errorf("got frame %d %q, wanted text hello", op, msg)
+	}
+
+	// Fragmented binary message, with a ping in between
+	big := bytes.Repeat([]byte{0xff}, 300)
+	c.writeFrame(false, false, opBinary, big[:100])
+	c.writeFrame(true, false, opPing, []byte("ping"))
+	c.writeFrame(true, false, opContinuation, big[100:])
+	if op, _, msg := c.readFrame(); op != opPong || string(msg) != "ping" {
+		t.Errorf("got frame %d %q, wanted pong", op, msg)
+	}
+	if op, _, msg := c.readFrame(); op != opBinary || !bytes.Equal(msg, big) {
+		t.Errorf("got frame %d with %d bytes, wanted binary with %d bytes", op, len(msg), len(big))
+	}
+
+	c.writeFrame(true, false, opClose, closePayload(CloseGoingAway, "bye"))
+	c.expectClose(CloseGoingAway)
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		t.Errorf("got error %v, wanted EOF after close", err)
+	}
+
+	select {
+	case line := <-logs:
+		if !strings.Contains(line, `"GET /ws HTTP/1.1" 101`) {
+			t.Errorf("got access log %q, wanted status 101", line)
+		}
+	case <-time.After(time.Second):
+		t.Error("handler didn't finish after the connection was closed")
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	srv := newEchoServer(t, &Options{ReadLimit: 10}, ioutil.Discard)
+	tests := []struct {
+		fin, rsv1 bool
+		opcode    byte
+		payload   []byte
+		code      int
+	}{
+		{true, false, opText, []byte{0xff, 0xfe}, CloseInvalidPayload},
+		{true, false, opText, []byte("more than ten bytes"), CloseMessageTooBig},
+		{true, true, opText, []byte("hi"), CloseProtocolError},
+		{true, false, opContinuation, []byte("hi"), CloseProtocolError},
+		{true, false, 0x3, []byte("hi"), CloseProtocolError},
+		{false, false, opPing, []byte("hi"), CloseProtocolError},
+		{true, false, opClose, []byte{0x03}, CloseProtocolError},
+		{true, false, opClose, closePayload(1005, ""), CloseProtocolError},
+	}
+	for _, tt := range tests {
+		c := dial(t, srv.URL, nil)
+		c.writeFrame(tt.fin, tt.rsv1, tt.opcode, tt.payload)
+		c.expectClose(tt.code)
+	}
+
+	t.Run("unmasked frame", func(t *testing.T) {
+		c := dial(t, srv.URL, nil)
+		_, _ = c.conn.Write([]byte{0x81, 0x02, 'h', 'i'})
+		c.expectClose(CloseProtocolError)
+	})
+}
+
+func TestCompression(t *testing.T) {
+	srv := newEchoServer(t, &Options{Compression: true}, ioutil.Discard)
+
+	c := dial(t, srv.URL, map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; server_max_window_bits=10"})
+	assert.Header(t, c.resp, "Sec-WebSocket-Extensions", "")
+
+	c = dial(t, srv.URL, map[string]string{"Sec-WebSocket-Extensions": "x-custom, permessage-deflate; client_max_window_bits"})
+	assert.Header(t, c.resp, "Sec-WebSocket-Extensions",
+		"permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+
+	msg := strings.Repeat("hello world ", 20)
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = w.Write([]byte(msg))
+	_ = w.Flush()
+	c.writeFrame(true, true, opText, bytes.TrimSuffix(buf.Bytes(), deflateTail))
+
+	op, rsv1, payload := c.readFrame()
+	if op != opText || !rsv1 {
+		t.Fatalf("got frame %d (compressed %v), wanted compressed text", op, rsv1)
+	}
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)))
+	got, err := ioutil.ReadAll(r)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+	if string(got) != msg {
+		t.Errorf("got message %q, wanted %q", got, msg)
+	}
+}